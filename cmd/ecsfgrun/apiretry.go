@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+const maxAPIBackoff = 20 * time.Second
+
+// apiRetrier retries the AWS API calls failed by throttling or a transient error, and limits
+// the rate of the calls. The wait before a retry doubles with jitter, so that many ecsfgrun
+// running at once do not retry at the same moment.
+type apiRetrier struct {
+	maxAttempts int
+	base        time.Duration
+	limiter     *rateLimiter
+}
+
+func newAPIRetrier(env environments) *apiRetrier {
+	return &apiRetrier{
+		maxAttempts: env.APIMaxAttempts,
+		base:        env.APIRetryBase,
+		limiter:     newRateLimiter(env.APIRateLimit),
+	}
+}
+
+// do calls f until it succeeds, it fails by an error which retryable rejects,
+// or API_MAX_ATTEMPTS are used up.
+func (r *apiRetrier) do(name string, retryable func(error) bool, f func() error) error {
+	backoff := r.base
+	for attempt := 1; ; attempt++ {
+		r.limiter.wait()
+		err := f()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if attempt >= r.maxAttempts {
+			log.Printf("%s failed %d times. give up", name, attempt)
+			return err
+		}
+		wait := jitter(backoff)
+		log.Printf("%s failed: %s (attempt %d/%d). retry after %s", name, err, attempt, r.maxAttempts, wait)
+		time.Sleep(wait)
+		backoff *= 2
+		if backoff > maxAPIBackoff {
+			backoff = maxAPIBackoff
+		}
+	}
+}
+
+// jitter returns a random duration between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	if d < 2 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// isThrottleError reports whether the call was throttled. A throttled call was not processed.
+func isThrottleError(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	e, ok := err.(awserr.RequestFailure)
+	return ok && e.StatusCode() == 429
+}
+
+// isTransientError reports whether the call may succeed when it is retried: throttling,
+// a server error, or a network error while sending the request. Other errors are permanent.
+func isTransientError(err error) bool {
+	if isThrottleError(err) {
+		return true
+	}
+	if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() >= 500 {
+		return true
+	}
+	e, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch e.Code() {
+	case ecs.ErrCodeServerException, cloudwatchlogs.ErrCodeServiceUnavailableException, "InternalFailure", "ServiceUnavailable":
+		return true
+	case request.ErrCodeRequestError:
+		_, ok := e.OrigErr().(net.Error)
+		return ok
+	}
+	return false
+}
+
+// rateLimiter spaces the calls evenly at the rate per second. A zero rate does not limit the calls.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (l *rateLimiter) wait() {
+	if l.interval == 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	d := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(d)
+}
+
+// retryingECS retries the ECS API calls. RunTask and RegisterTaskDefinition are retried only when
+// they are throttled, because a call failed by a server error may have started a task or
+// registered a revision.
+type retryingECS struct {
+	ecsiface.ECSAPI
+	r *apiRetrier
+}
+
+func newRetryingECS(client ecsiface.ECSAPI, env environments) *retryingECS {
+	return &retryingECS{ECSAPI: client, r: newAPIRetrier(env)}
+}
+
+func (c *retryingECS) RunTask(input *ecs.RunTaskInput) (res *ecs.RunTaskOutput, err error) {
+	err = c.r.do("RunTask", isThrottleError, func() error {
+		res, err = c.ECSAPI.RunTask(input)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryingECS) RegisterTaskDefinition(input *ecs.RegisterTaskDefinitionInput) (res *ecs.RegisterTaskDefinitionOutput, err error) {
+	err = c.r.do("RegisterTaskDefinition", isThrottleError, func() error {
+		res, err = c.ECSAPI.RegisterTaskDefinition(input)
+		return err
+	})
+	return res, err
+}
+
+// DescribeTasks also retries the MISSING failures, because a task is not found for a while
+// after RunTask. When the attempts are used up, the failures are returned as they are.
+func (c *retryingECS) DescribeTasks(input *ecs.DescribeTasksInput) (res *ecs.DescribeTasksOutput, err error) {
+	err = c.r.do("DescribeTasks", isTransientOrMissing, func() error {
+		res, err = c.ECSAPI.DescribeTasks(input)
+		if err == nil && hasMissingTask(res) {
+			return errTaskMissing
+		}
+		return err
+	})
+	if err == errTaskMissing {
+		return res, nil
+	}
+	return res, err
+}
+
+var errTaskMissing = errors.New("DescribeTasks returned MISSING")
+
+func isTransientOrMissing(err error) bool {
+	return err == errTaskMissing || isTransientError(err)
+}
+
+func hasMissingTask(res *ecs.DescribeTasksOutput) bool {
+	for _, failure := range res.Failures {
+		if aws.StringValue(failure.Reason) == "MISSING" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *retryingECS) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (res *ecs.DescribeTaskDefinitionOutput, err error) {
+	err = c.r.do("DescribeTaskDefinition", isTransientError, func() error {
+		res, err = c.ECSAPI.DescribeTaskDefinition(input)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryingECS) DescribeServices(input *ecs.DescribeServicesInput) (res *ecs.DescribeServicesOutput, err error) {
+	err = c.r.do("DescribeServices", isTransientError, func() error {
+		res, err = c.ECSAPI.DescribeServices(input)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryingECS) ListTasks(input *ecs.ListTasksInput) (res *ecs.ListTasksOutput, err error) {
+	err = c.r.do("ListTasks", isTransientError, func() error {
+		res, err = c.ECSAPI.ListTasks(input)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryingECS) StopTask(input *ecs.StopTaskInput) (res *ecs.StopTaskOutput, err error) {
+	err = c.r.do("StopTask", isTransientError, func() error {
+		res, err = c.ECSAPI.StopTask(input)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryingECS) DeregisterTaskDefinition(input *ecs.DeregisterTaskDefinitionInput) (res *ecs.DeregisterTaskDefinitionOutput, err error) {
+	err = c.r.do("DeregisterTaskDefinition", isTransientError, func() error {
+		res, err = c.ECSAPI.DeregisterTaskDefinition(input)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryingECS) DeleteTaskDefinitions(input *ecs.DeleteTaskDefinitionsInput) (res *ecs.DeleteTaskDefinitionsOutput, err error) {
+	err = c.r.do("DeleteTaskDefinitions", isTransientError, func() error {
+		res, err = c.ECSAPI.DeleteTaskDefinitions(input)
+		return err
+	})
+	return res, err
+}
+
+// retryingLogs retries the CloudWatch Logs API calls.
+type retryingLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	r *apiRetrier
+}
+
+func newRetryingLogs(client cloudwatchlogsiface.CloudWatchLogsAPI, env environments) *retryingLogs {
+	return &retryingLogs{CloudWatchLogsAPI: client, r: newAPIRetrier(env)}
+}
+
+func (c *retryingLogs) GetLogEvents(input *cloudwatchlogs.GetLogEventsInput) (res *cloudwatchlogs.GetLogEventsOutput, err error) {
+	err = c.r.do("GetLogEvents", isTransientError, func() error {
+		res, err = c.CloudWatchLogsAPI.GetLogEvents(input)
+		return err
+	})
+	return res, err
+}
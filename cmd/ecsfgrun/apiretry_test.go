@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// flakyECS fails DescribeTasks and RunTask with err the first failures calls.
+type flakyECS struct {
+	mockedECS
+	err      error
+	failures int
+	calls    int
+}
+
+func (m *flakyECS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, m.err
+	}
+	return m.mockedECS.DescribeTasks(input)
+}
+
+func (m *flakyECS) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, m.err
+	}
+	return m.mockedECS.RunTask(input)
+}
+
+func TestRetryingECS(t *testing.T) {
+	throttle := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	server := awserr.NewRequestFailure(awserr.New(ecs.ErrCodeServerException, "internal error", nil), 500, "id")
+	invalid := awserr.New(ecs.ErrCodeInvalidParameterException, "invalid", nil)
+	var vtests = []struct {
+		err         error
+		failures    int
+		runTask     bool
+		expectErr   bool
+		expectCalls int
+	}{
+		{throttle, 2, false, false, 3},
+		{server, 2, false, false, 3},
+		{throttle, 10, false, true, 4},
+		{invalid, 2, false, true, 1},
+		{throttle, 2, true, false, 3},
+		{server, 2, true, true, 1},
+	}
+	for i, vt := range vtests {
+		m := &flakyECS{err: vt.err, failures: vt.failures}
+		client := newRetryingECS(m, environments{APIMaxAttempts: 4, APIRetryBase: time.Millisecond})
+		var err error
+		if vt.runTask {
+			_, err = client.RunTask(&ecs.RunTaskInput{})
+		} else {
+			_, err = client.DescribeTasks(&ecs.DescribeTasksInput{})
+		}
+		if (err != nil) != vt.expectErr {
+			t.Errorf("err %d: err:%v, want error:%v", i, err, vt.expectErr)
+		}
+		if m.calls != vt.expectCalls {
+			t.Errorf("err %d: calls = %d, want:%d", i, m.calls, vt.expectCalls)
+		}
+	}
+}
+
+// missingTaskECS answers DescribeTasks with MISSING the first missing calls.
+type missingTaskECS struct {
+	statusSequenceECS
+	missing int
+}
+
+func (m *missingTaskECS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	if m.missing > 0 {
+		m.missing--
+		return &ecs.DescribeTasksOutput{Failures: []*ecs.Failure{{Arn: aws.String("arn"), Reason: aws.String("MISSING")}}}, nil
+	}
+	return m.statusSequenceECS.DescribeTasks(input)
+}
+
+func TestRetryingECSMissingTask(t *testing.T) {
+	e := environments{APIMaxAttempts: 4, APIRetryBase: time.Millisecond}
+	m := &missingTaskECS{statusSequenceECS: statusSequenceECS{statuses: []string{"STOPPED"}}, missing: 2}
+	var b bytes.Buffer
+	code, err := readLog(&b, &streamingCWL{events: []string{"line1"}}, newRetryingECS(m, e), cloudwatchlogs.GetLogEventsInput{}, ecs.DescribeTasksInput{}, e)
+	if code != 3 || err != nil {
+		t.Errorf("readLog() = %d, %v, want:3, nil", code, err)
+	}
+
+	m = &missingTaskECS{statusSequenceECS: statusSequenceECS{statuses: []string{"STOPPED"}}, missing: 10}
+	code, err = readLog(&b, &streamingCWL{events: []string{"line1"}}, newRetryingECS(m, e), cloudwatchlogs.GetLogEventsInput{}, ecs.DescribeTasksInput{}, e)
+	if code != 2 || err != errTaskNotFound {
+		t.Errorf("readLog() = %d, %v, want:2, %v", code, err, errTaskNotFound)
+	}
+	if m.missing != 6 {
+		t.Errorf("readLog() DescribeTasks calls = %d, want:4", 10-m.missing)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	var vtests = []struct {
+		err      error
+		expected bool
+	}{
+		{awserr.New("ThrottlingException", "Rate exceeded", nil), true},
+		{awserr.New(request.ErrCodeRequestError, "send request failed", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), true},
+		{awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("test error")), false},
+		{awserr.NewRequestFailure(awserr.New("ServiceUnavailableException", "", nil), 503, "id"), true},
+		{awserr.NewRequestFailure(awserr.New("SlowDown", "", nil), 429, "id"), true},
+		{awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil), false},
+		{awserr.NewRequestFailure(awserr.New("AccessDeniedException", "", nil), 400, "id"), false},
+		{errors.New("test error"), false},
+	}
+	for i, vt := range vtests {
+		if r := isTransientError(vt.err); r != vt.expected {
+			t.Errorf("err %d:isTransientError(%v) = %v, want:%v", i, vt.err, r, vt.expected)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := 100 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		if r := jitter(d); r < d/2 || r > d {
+			t.Fatalf("jitter(%s) = %s, want between %s and %s", d, r, d/2, d)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		l.wait()
+	}
+	// the first call does not wait
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 calls at 100/s took %s, want 40ms or more", elapsed)
+	}
+	start = time.Now()
+	newRateLimiter(0).wait()
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("a zero rate waited %s", elapsed)
+	}
+}
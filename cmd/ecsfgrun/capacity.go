@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	// defaultCapacityProviders makes RunTask use the default capacity provider strategy of the cluster.
+	defaultCapacityProviders = "default"
+
+	capacityProviderFargate     = "FARGATE"
+	capacityProviderFargateSpot = "FARGATE_SPOT"
+)
+
+// useCapacityProviders reports whether the task is placed by capacity providers instead of the launch type.
+func useCapacityProviders(env environments) bool {
+	return len(env.CapacityProviders) > 0
+}
+
+// getLaunchType returns the launch type that the task will actually use.
+// It returns "" when it is decided by the default capacity provider strategy of the cluster.
+func getLaunchType(env environments) string {
+	if !useCapacityProviders(env) {
+		return env.LaunchType
+	}
+	strategy, err := parseCapacityProviders(env.CapacityProviders)
+	if err != nil || len(strategy) == 0 {
+		return ""
+	}
+	for _, item := range strategy {
+		switch aws.StringValue(item.CapacityProvider) {
+		case capacityProviderFargate, capacityProviderFargateSpot:
+		default:
+			return ecs.LaunchTypeEc2
+		}
+	}
+	return ecs.LaunchTypeFargate
+}
+
+// parseCapacityProviders parses items like "FARGATE_SPOT:3:base=1" (provider[:weight][:base=N]).
+// "default" returns nil, which leaves the strategy to the cluster.
+func parseCapacityProviders(items []string) ([]*ecs.CapacityProviderStrategyItem, error) {
+	if len(items) == 1 && items[0] == defaultCapacityProviders {
+		return nil, nil
+	}
+	res := make([]*ecs.CapacityProviderStrategyItem, 0, len(items))
+	for _, item := range items {
+		fields := strings.Split(strings.TrimSpace(item), ":")
+		if fields[0] == "" {
+			return nil, fmt.Errorf("invalid capacity provider %q: the name is empty", item)
+		}
+		s := &ecs.CapacityProviderStrategyItem{CapacityProvider: aws.String(fields[0])}
+		for _, f := range fields[1:] {
+			key, value := "weight", f
+			if kv := strings.SplitN(f, "=", 2); len(kv) == 2 {
+				key, value = kv[0], kv[1]
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid capacity provider %q: %s must be a non-negative integer", item, key)
+			}
+			switch key {
+			case "weight":
+				s.Weight = aws.Int64(n)
+			case "base":
+				s.Base = aws.Int64(n)
+			default:
+				return nil, fmt.Errorf("invalid capacity provider %q: unknown key %q", item, key)
+			}
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
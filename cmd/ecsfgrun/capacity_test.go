@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestParseCapacityProviders(t *testing.T) {
+	var vtests = []struct {
+		input    []string
+		expected []*ecs.CapacityProviderStrategyItem
+		err      bool
+	}{
+		{
+			[]string{"FARGATE_SPOT:3:base=0", "FARGATE:1"},
+			[]*ecs.CapacityProviderStrategyItem{
+				{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(3), Base: aws.Int64(0)},
+				{CapacityProvider: aws.String("FARGATE"), Weight: aws.Int64(1)},
+			},
+			false,
+		},
+		{
+			[]string{"gpu:base=1:weight=2"},
+			[]*ecs.CapacityProviderStrategyItem{
+				{CapacityProvider: aws.String("gpu"), Weight: aws.Int64(2), Base: aws.Int64(1)},
+			},
+			false,
+		},
+		{[]string{"default"}, nil, false},
+		{[]string{"FARGATE:a"}, nil, true},
+		{[]string{"FARGATE:hoge=1"}, nil, true},
+		{[]string{":1"}, nil, true},
+	}
+	for i, vt := range vtests {
+		res, err := parseCapacityProviders(vt.input)
+		if (err != nil) != vt.err {
+			t.Errorf("err %d:parseCapacityProviders(%v) = err:%v, want err:%v", i, vt.input, err, vt.err)
+			continue
+		}
+		if len(res) != len(vt.expected) {
+			t.Errorf("err %d:parseCapacityProviders(%v) = %v, want:%v", i, vt.input, res, vt.expected)
+			continue
+		}
+		for j := range res {
+			if aws.StringValue(res[j].CapacityProvider) != aws.StringValue(vt.expected[j].CapacityProvider) ||
+				aws.Int64Value(res[j].Weight) != aws.Int64Value(vt.expected[j].Weight) ||
+				aws.Int64Value(res[j].Base) != aws.Int64Value(vt.expected[j].Base) {
+				t.Errorf("err %d:parseCapacityProviders(%v)[%d] = %v, want:%v", i, vt.input, j, res[j], vt.expected[j])
+			}
+		}
+	}
+}
+
+func TestGetLaunchType(t *testing.T) {
+	var vtests = []struct {
+		env      environments
+		expected string
+	}{
+		{environments{LaunchType: "EC2"}, "EC2"},
+		{environments{LaunchType: "EC2", CapacityProviders: []string{"FARGATE_SPOT:1", "FARGATE"}}, "FARGATE"},
+		{environments{LaunchType: "FARGATE", CapacityProviders: []string{"my-asg-provider"}}, "EC2"},
+		{environments{LaunchType: "FARGATE", CapacityProviders: []string{"default"}}, ""},
+	}
+	for i, vt := range vtests {
+		if res := getLaunchType(vt.env); res != vt.expected {
+			t.Errorf("err %d:getLaunchType() = %q, want:%q", i, res, vt.expected)
+		}
+	}
+}
+
+func TestCreateRunParamCapacityProviders(t *testing.T) {
+	e := environments{
+		LaunchType:        "FARGATE",
+		CapacityProviders: []string{"FARGATE_SPOT:3", "FARGATE:1"},
+		Subnets:           []string{"subnet-1"},
+		TaskDefinition:    "hoge:1",
+	}
+	m := mockedECS{dtdresp: ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{NetworkMode: aws.String("awsvpc")}}}
+	input, err := createRunParam(&m, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.LaunchType != nil {
+		t.Errorf("createRunParam() LaunchType = %s, want:nil", *input.LaunchType)
+	}
+	if len(input.CapacityProviderStrategy) != 2 {
+		t.Errorf("createRunParam() CapacityProviderStrategy = %v", input.CapacityProviderStrategy)
+	}
+	e.CapacityProviders = []string{"default"}
+	input, err = createRunParam(&m, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.LaunchType != nil || input.CapacityProviderStrategy != nil {
+		t.Errorf("createRunParam() = LaunchType:%v, CapacityProviderStrategy:%v, want:nil", input.LaunchType, input.CapacityProviderStrategy)
+	}
+}
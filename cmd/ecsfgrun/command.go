@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	commandModeExec  = "exec"
+	commandModeShell = "shell"
+)
+
+// createCommand returns the command of the container override.
+// In exec mode the command line is sent as it is. In shell mode it is joined with spaces and
+// run by SHELL_ENTRYPOINT, so that pipes and variables are interpreted in the container.
+func createCommand(env environments, cmdline []string) ([]*string, error) {
+	switch env.CommandMode {
+	case "", commandModeExec:
+		return createCmd(cmdline), nil
+	case commandModeShell:
+		entrypoint := strings.Fields(env.ShellEntrypoint)
+		if len(entrypoint) == 0 {
+			return nil, fmt.Errorf("SHELL_ENTRYPOINT is empty: use /bin/sh -c")
+		}
+		return aws.StringSlice(append(entrypoint, strings.Join(cmdline, " "))), nil
+	}
+	return nil, fmt.Errorf("COMMAND_MODE=%q is unknown: use exec or shell", env.CommandMode)
+}
+
+// isSubcommand reports whether args start with the subcommand name.
+// An explicit command mode takes every argument as the command line, e.g. "ecsfgrun -exec ps aux".
+func isSubcommand(args []string, env environments, name string) bool {
+	return len(args) > 0 && args[0] == name && len(env.CommandMode) == 0
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestCreateCommand(t *testing.T) {
+	var vtests = []struct {
+		env      environments
+		cmdline  []string
+		expected []string
+		err      bool
+	}{
+		{environments{}, []string{"echo", "$FOO | wc"}, []string{"echo", "$FOO | wc"}, false},
+		{environments{CommandMode: "exec"}, []string{"ps", "aux"}, []string{"ps", "aux"}, false},
+		{environments{CommandMode: "shell", ShellEntrypoint: "/bin/sh -c"}, []string{"echo $FOO | wc"}, []string{"/bin/sh", "-c", "echo $FOO | wc"}, false},
+		{environments{CommandMode: "shell", ShellEntrypoint: "bash -lc"}, []string{"echo", "$FOO"}, []string{"bash", "-lc", "echo $FOO"}, false},
+		{environments{CommandMode: "shell"}, []string{"echo"}, nil, true},
+		{environments{CommandMode: "hoge"}, []string{"echo"}, nil, true},
+	}
+	for i, vt := range vtests {
+		res, err := createCommand(vt.env, vt.cmdline)
+		if (err != nil) != vt.err {
+			t.Errorf("err %d:createCommand() = err:%v, want err:%v", i, err, vt.err)
+			continue
+		}
+		if strings.Join(aws.StringValueSlice(res), "\x00") != strings.Join(vt.expected, "\x00") {
+			t.Errorf("err %d:createCommand() = %q, want:%q", i, aws.StringValueSlice(res), vt.expected)
+		}
+	}
+}
+
+func TestIsSubcommand(t *testing.T) {
+	if !isSubcommand([]string{"ps", "-a"}, environments{}, "ps") {
+		t.Error("isSubcommand(ps) = false, want:true")
+	}
+	if isSubcommand([]string{"ps", "aux"}, environments{CommandMode: "exec"}, "ps") {
+		t.Error("isSubcommand(ps) with -exec = true, want:false")
+	}
+	if isSubcommand(nil, environments{}, "ps") {
+		t.Error("isSubcommand() = true, want:false")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const maskedValue = "********"
+
+type dryRunOutput struct {
+	RegisterTaskDefinitionInput *ecs.RegisterTaskDefinitionInput
+	RunTaskInput                *ecs.RunTaskInput
+	Container                   string
+	Command                     []string
+	LogGroup                    string
+	LogStream                   string
+}
+
+func printDryRun(w io.Writer, client ecsiface.ECSAPI, input *ecs.RunTaskInput, reg *ecs.RegisterTaskDefinitionInput, env environments) error {
+	container, logContainer, err := resolveContainers(client, input.TaskDefinition)
+	if err != nil {
+		return err
+	}
+	maskRe, err := regexp.Compile(env.MaskPattern)
+	if err != nil {
+		return fmt.Errorf("invalid MASK_PATTERN err:%s", err)
+	}
+	if env.Count > 1 {
+		// print the request of the first task. the others differ only in ECSFGRUN_INDEX
+		input = withIndex(input, 0, env.Count)
+	}
+	out := dryRunOutput{
+		RegisterTaskDefinitionInput: maskContainerEnvs(reg, maskRe),
+		RunTaskInput:                maskEnvs(input, maskRe),
+		Container:                   container,
+		Command:                     getOverrideCommand(input, reg, container),
+		LogGroup:                    getLogGroup(aws.StringValue(input.TaskDefinition)),
+		LogStream:                   getLogStream(logContainer, "<task-id>"),
+	}
+	b, err := marshalDryRun(out, env.DryRunFormat)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// resolveContainers returns the container that receives the overrides and the container whose logs are read.
+func resolveContainers(client ecsiface.ECSAPI, taskDef *string) (target, logContainer string, err error) {
+	definition, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: taskDef})
+	if err != nil {
+		return "", "", err
+	}
+	if definition.TaskDefinition == nil || len(definition.TaskDefinition.ContainerDefinitions) == 0 {
+		return "", "", fmt.Errorf("no container definitions in %s", aws.StringValue(taskDef))
+	}
+	target = aws.StringValue(getTargetContainer(definition.TaskDefinition))
+	logContainer = aws.StringValue(definition.TaskDefinition.ContainerDefinitions[0].Name)
+	return target, logContainer, nil
+}
+
+// getOverrideCommand returns the command array that the container will run, or nil if the task definition decides it.
+func getOverrideCommand(input *ecs.RunTaskInput, reg *ecs.RegisterTaskDefinitionInput, container string) []string {
+	if input.Overrides != nil {
+		for _, o := range input.Overrides.ContainerOverrides {
+			if aws.StringValue(o.Name) == container && len(o.Command) > 0 {
+				return aws.StringValueSlice(o.Command)
+			}
+		}
+	}
+	if reg != nil {
+		for _, c := range reg.ContainerDefinitions {
+			if aws.StringValue(c.Name) == container {
+				return aws.StringValueSlice(c.Command)
+			}
+		}
+	}
+	return nil
+}
+
+// maskEnvs returns a copy of input whose override environment values are masked if the name matches re.
+func maskEnvs(input *ecs.RunTaskInput, re *regexp.Regexp) *ecs.RunTaskInput {
+	if input.Overrides == nil {
+		return input
+	}
+	res := *input
+	overrides := *input.Overrides
+	overrides.ContainerOverrides = make([]*ecs.ContainerOverride, len(input.Overrides.ContainerOverrides))
+	for i, o := range input.Overrides.ContainerOverrides {
+		if o == nil {
+			continue
+		}
+		co := *o
+		co.Environment = maskKeyValues(o.Environment, re)
+		overrides.ContainerOverrides[i] = &co
+	}
+	res.Overrides = &overrides
+	return &res
+}
+
+// maskContainerEnvs returns a copy of reg whose environment values are masked if the name matches re.
+// OVERRIDE_FALLBACK moves the override environment into reg.
+func maskContainerEnvs(reg *ecs.RegisterTaskDefinitionInput, re *regexp.Regexp) *ecs.RegisterTaskDefinitionInput {
+	if reg == nil {
+		return nil
+	}
+	res := *reg
+	res.ContainerDefinitions = make([]*ecs.ContainerDefinition, len(reg.ContainerDefinitions))
+	for i, c := range reg.ContainerDefinitions {
+		if c == nil {
+			continue
+		}
+		cd := *c
+		cd.Environment = maskKeyValues(c.Environment, re)
+		res.ContainerDefinitions[i] = &cd
+	}
+	return &res
+}
+
+func maskKeyValues(kvs []*ecs.KeyValuePair, re *regexp.Regexp) []*ecs.KeyValuePair {
+	if kvs == nil {
+		return nil
+	}
+	res := make([]*ecs.KeyValuePair, len(kvs))
+	for i, kv := range kvs {
+		v := *kv
+		if re.MatchString(aws.StringValue(kv.Name)) {
+			v.Value = aws.String(maskedValue)
+		}
+		res[i] = &v
+	}
+	return res
+}
+
+func marshalDryRun(v interface{}, format string) ([]byte, error) {
+	m, err := pruneJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "json", "":
+		return encodeJSON(m, "  ")
+	case "yaml":
+		return yaml.Marshal(m)
+	}
+	return nil, fmt.Errorf("unknown dry-run format: %s", format)
+}
+
+// pruneJSON returns the JSON value of v without the unset fields of the SDK structs.
+// The SDK structs have no omitempty tags, so json.Marshal writes them as null.
+func pruneJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return pruneNull(m), nil
+}
+
+// encodeJSON encodes v without escaping HTML characters, as the SDK does on the wire.
+func encodeJSON(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func pruneNull(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e == nil {
+				delete(t, k)
+				continue
+			}
+			t[k] = pruneNull(e)
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = pruneNull(t[i])
+		}
+	}
+	return v
+}
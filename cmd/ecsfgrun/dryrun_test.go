@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestMaskEnvs(t *testing.T) {
+	input := &ecs.RunTaskInput{
+		Overrides: &ecs.TaskOverride{
+			ContainerOverrides: []*ecs.ContainerOverride{
+				{
+					Name: aws.String("hoge"),
+					Environment: []*ecs.KeyValuePair{
+						{Name: aws.String("DB_PASSWORD"), Value: aws.String("hogehoge")},
+						{Name: aws.String("DB_HOST"), Value: aws.String("fugafuga")},
+					},
+				},
+			},
+		},
+	}
+	res := maskEnvs(input, regexp.MustCompile(env.MaskPattern))
+	envs := res.Overrides.ContainerOverrides[0].Environment
+	if *envs[0].Value != maskedValue {
+		t.Errorf("maskEnvs() DB_PASSWORD = %s, want:%s", *envs[0].Value, maskedValue)
+	}
+	if *envs[1].Value != "fugafuga" {
+		t.Errorf("maskEnvs() DB_HOST = %s, want:fugafuga", *envs[1].Value)
+	}
+	if *input.Overrides.ContainerOverrides[0].Environment[0].Value != "hogehoge" {
+		t.Error("maskEnvs() modified the original input")
+	}
+}
+
+func TestMaskContainerEnvs(t *testing.T) {
+	reg := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("hoge"),
+				Environment: []*ecs.KeyValuePair{
+					{Name: aws.String("DB_PASSWORD"), Value: aws.String("hogehoge")},
+					{Name: aws.String("DB_HOST"), Value: aws.String("fugafuga")},
+				},
+			},
+		},
+	}
+	res := maskContainerEnvs(reg, regexp.MustCompile(env.MaskPattern))
+	envs := res.ContainerDefinitions[0].Environment
+	if *envs[0].Value != maskedValue {
+		t.Errorf("maskContainerEnvs() DB_PASSWORD = %s, want:%s", *envs[0].Value, maskedValue)
+	}
+	if *envs[1].Value != "fugafuga" {
+		t.Errorf("maskContainerEnvs() DB_HOST = %s, want:fugafuga", *envs[1].Value)
+	}
+	if *reg.ContainerDefinitions[0].Environment[0].Value != "hogehoge" {
+		t.Error("maskContainerEnvs() modified the original input")
+	}
+	if maskContainerEnvs(nil, regexp.MustCompile(env.MaskPattern)) != nil {
+		t.Error("maskContainerEnvs(nil) != nil")
+	}
+}
+
+func TestPrintDryRun(t *testing.T) {
+	var vtests = []struct {
+		format   string
+		count    int
+		contains []string
+		err      bool
+	}{
+		{"json", 1, []string{`"Cluster": "test"`, `"LogGroup": "/ecs/hoge"`, `"LogStream": "ecs/first/<task-id>"`, `"Container": "second"`}, false},
+		{"yaml", 1, []string{"Cluster: test", "LogGroup: /ecs/hoge", "Container: second", "Command:\n- /bin/sh\n- -c\n- echo $FOO | wc\n"}, false},
+		{"yaml", 3, []string{"- Name: ECSFGRUN_INDEX\n        Value: \"0\"\n", "- Name: ECSFGRUN_COUNT\n        Value: \"3\"\n"}, false},
+		{"xml", 1, nil, true},
+	}
+	m := mockedECS{
+		dtdresp: ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("first")},
+					{Name: aws.String("second")},
+				},
+			},
+		},
+	}
+	input := &ecs.RunTaskInput{
+		Cluster:        aws.String("test"),
+		TaskDefinition: aws.String("hoge:1"),
+		Overrides: &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{
+			{Name: aws.String("second"), Command: aws.StringSlice([]string{"/bin/sh", "-c", "echo $FOO | wc"})},
+		}},
+	}
+	for i, vt := range vtests {
+		var b bytes.Buffer
+		e := env
+		e.DryRunFormat = vt.format
+		e.Count = vt.count
+		err := printDryRun(&b, &m, input, nil, e)
+		if (err != nil) != vt.err {
+			t.Errorf("err %d:printDryRun() = err:%v, want err:%v", i, err, vt.err)
+			continue
+		}
+		for _, c := range vt.contains {
+			if !strings.Contains(b.String(), c) {
+				t.Errorf("err %d:printDryRun() = %s, want contains:%s", i, b.String(), c)
+			}
+		}
+		if vt.count == 1 && strings.Contains(b.String(), indexEnvName) {
+			t.Errorf("err %d:printDryRun() = %s, want no %s", i, b.String(), indexEnvName)
+		}
+		if vt.format == "json" && strings.Contains(b.String(), "null") {
+			t.Errorf("err %d:printDryRun() = %s, contains null", i, b.String())
+		}
+		if vt.format == "json" && !json.Valid(b.Bytes()) {
+			t.Errorf("err %d:printDryRun() = %s, invalid json", i, b.String())
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// hasEnvOverride reports whether the environment of the container is given explicitly.
+func hasEnvOverride(env environments) bool {
+	return len(env.EnvFiles)+len(env.Envs)+len(env.S3EnvFiles) > 0
+}
+
+// sendsOverrideEnv reports whether the override environment is sent to the container.
+// Without a command or an explicit environment, the task runs with the environment of its task definition.
+func sendsOverrideEnv(env environments, cmdline []string) bool {
+	return len(cmdline) > 0 || len(env.Script) > 0 || hasEnvOverride(env)
+}
+
+// getOverrideVars returns the override environment variables. Later sources take precedence:
+// the -env-file files in order, the variables with OVERRIDE_ENV_PREFIX, then ENVS and -env.
+// The values may be secret references.
+func getOverrideVars(env environments) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, path := range env.EnvFiles {
+		fileVars, err := loadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileVars {
+			vars[k] = v
+		}
+	}
+	for _, kv := range makeEnvs(env.OverrideEnvPrefix) {
+		vars[aws.StringValue(kv.Name)] = aws.StringValue(kv.Value)
+	}
+	for _, e := range env.Envs {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || !envNameRe.MatchString(kv[0]) {
+			return nil, fmt.Errorf("invalid env %q: use KEY=VALUE", e)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return vars, nil
+}
+
+// getOverrideEnvs returns the environment of the container override sorted by name.
+// Secret references are left to the secrets of the container definition.
+func getOverrideEnvs(env environments) ([]*ecs.KeyValuePair, error) {
+	vars, err := getOverrideVars(env)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(vars))
+	for name, v := range vars {
+		if !isSecretRef(v) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if env.Verbose {
+		log.Printf("override environment: %s", strings.Join(names, ", "))
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	res := make([]*ecs.KeyValuePair, len(names))
+	for i, name := range names {
+		res[i] = &ecs.KeyValuePair{Name: aws.String(name), Value: aws.String(vars[name])}
+	}
+	return res, nil
+}
+
+// getEnvironmentFiles returns the S3 environment files of the container override.
+func getEnvironmentFiles(env environments) []*ecs.EnvironmentFile {
+	var res []*ecs.EnvironmentFile
+	for _, arn := range env.S3EnvFiles {
+		res = append(res, &ecs.EnvironmentFile{Type: aws.String(ecs.EnvironmentFileTypeS3), Value: aws.String(arn)})
+	}
+	return res
+}
+
+func loadEnvFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseEnvFile(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return vars, nil
+}
+
+// parseEnvFile parses a dotenv file. A line is "KEY=VALUE" with an optional "export " prefix.
+// Single quoted values are taken as they are. Double quoted values may span lines and
+// interpret \n, \r, \t, \" and \\. Quoted values keep their spaces. Unquoted values are
+// trimmed and end at " #".
+func parseEnvFile(s string) (map[string]string, error) {
+	vars := map[string]string{}
+	lineNo := 0
+	for len(s) > 0 {
+		lineNo++
+		var line string
+		line, s = cutLine(s)
+		// the trailing spaces are kept, which may be in a quoted value
+		line = strings.TrimLeft(line, " \t")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		kv := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("line %d: use KEY=VALUE", lineNo)
+		}
+		value := strings.TrimLeft(kv[1], " \t")
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNo)
+			}
+			vars[name] = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// the value may continue on the following lines
+			rest := value[1:]
+			if len(s) > 0 {
+				rest += "\n" + s
+			}
+			v, n, ok := unquoteDouble(rest)
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated double quote", lineNo)
+			}
+			lineNo += strings.Count(rest[:n], "\n")
+			// skip the rest of the line after the closing quote
+			_, s = cutLine(rest[n:])
+			vars[name] = v
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			vars[name] = strings.TrimSpace(value)
+		}
+	}
+	return vars, nil
+}
+
+// cutLine returns the first line of s and the rest.
+func cutLine(s string) (string, string) {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSuffix(s[:i], "\r"), s[i+1:]
+	}
+	return strings.TrimSuffix(s, "\r"), ""
+}
+
+// unquoteDouble reads a double quoted value up to the closing quote.
+// It returns the value and the length of s consumed including the quote.
+func unquoteDouble(s string) (string, int, bool) {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, true
+		case '\\':
+			if i+1 == len(s) {
+				return "", 0, false
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const testEnvFile = "./test/override.env"
+
+func TestLoadEnvFile(t *testing.T) {
+	vars, err := loadEnvFile(testEnvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"MODE":     "dev",
+		"NAME":     "hoge",
+		"GREETING": "hello\n\"world\"",
+		"MULTI":    "line1\nline2",
+		"RAW":      `a\nb # not a comment`,
+		"TOKEN":    "ssm:/dev/token",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("loadEnvFile() = %#v, want:%#v", vars, expected)
+	}
+	var vtests = []struct {
+		s        string
+		expected string
+	}{
+		{"A=\"  x  \n  y  \"\n", "  x  \n  y  "},
+		{"A=\"x \t\n\n\" # comment", "x \t\n\n"},
+		{"  export A=' x '  ", " x "},
+		{"A= x  # comment", "x"},
+	}
+	for i, vt := range vtests {
+		if vars, err := parseEnvFile(vt.s); err != nil || vars["A"] != vt.expected {
+			t.Errorf("err %d:parseEnvFile(%q) = %q, %v, want:%q", i, vt.s, vars["A"], err, vt.expected)
+		}
+	}
+	for i, s := range []string{"hoge", "1A=b", `A="b`, "A='b"} {
+		if _, err := parseEnvFile(s); err == nil {
+			t.Errorf("err %d:parseEnvFile(%q) = nil, want error", i, s)
+		}
+	}
+}
+
+func TestGetOverrideEnvs(t *testing.T) {
+	os.Setenv("ENVFILETEST_NAME", "fuga")
+	os.Setenv("ENVFILETEST_MODE", "prod")
+	defer os.Unsetenv("ENVFILETEST_NAME")
+	defer os.Unsetenv("ENVFILETEST_MODE")
+	e := environments{
+		OverrideEnvPrefix: "ENVFILETEST_",
+		EnvFiles:          []string{testEnvFile},
+		Envs:              []string{"MODE=stg", "EMPTY="},
+	}
+	kvs, err := getOverrideEnvs(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]string{
+		{"EMPTY", ""},
+		{"GREETING", "hello\n\"world\""},
+		{"MODE", "stg"},
+		{"MULTI", "line1\nline2"},
+		{"NAME", "fuga"},
+		{"RAW", `a\nb # not a comment`},
+	}
+	if len(kvs) != len(expected) {
+		t.Fatalf("getOverrideEnvs() = %v", kvs)
+	}
+	for i, kv := range kvs {
+		if aws.StringValue(kv.Name) != expected[i][0] || aws.StringValue(kv.Value) != expected[i][1] {
+			t.Errorf("err %d:getOverrideEnvs() = %s=%q, want:%s=%q", i, aws.StringValue(kv.Name), aws.StringValue(kv.Value), expected[i][0], expected[i][1])
+		}
+	}
+	secrets, err := getSecrets(e, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || aws.StringValue(secrets[0].ValueFrom) != "/dev/token" {
+		t.Errorf("getSecrets() = %v", secrets)
+	}
+	if _, err := getOverrideEnvs(environments{Envs: []string{"hoge"}}); err == nil {
+		t.Error("getOverrideEnvs() = nil, want error")
+	}
+}
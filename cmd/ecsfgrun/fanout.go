@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+const (
+	// maxConcurrentRunTask is the number of RunTask calls in flight at once.
+	maxConcurrentRunTask = 10
+
+	indexEnvName = "ECSFGRUN_INDEX"
+	countEnvName = "ECSFGRUN_COUNT"
+)
+
+// runFanout runs COUNT copies of the task and waits for all of them. Each task gets its index
+// (0 to COUNT-1) in ECSFGRUN_INDEX, so every task is started by its own RunTask call.
+// At most maxConcurrentRunTask calls are in flight at once. The logs are prefixed by the index.
+func runFanout(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, input *ecs.RunTaskInput, env environments) (int, error) {
+	client := &runTaskLimiter{ECSAPI: ecsSv, sem: make(chan struct{}, maxConcurrentRunTask)}
+	var mu sync.Mutex
+	codes := make([]int, env.Count)
+	errs := make([]error, env.Count)
+	var wg sync.WaitGroup
+	for i := 0; i < env.Count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pw := &prefixWriter{w: w, mu: &mu, prefix: fmt.Sprintf("[%d] ", i)}
+			codes[i], errs[i] = runWithRetry(pw, client, logsSv, withIndex(input, i, env.Count), env)
+			pw.Flush() // nolint errcheck
+		}(i)
+	}
+	wg.Wait()
+	return summarizeFanout(w, codes, errs)
+}
+
+// summarizeFanout prints the result of each task and returns the first failure.
+func summarizeFanout(w io.Writer, codes []int, errs []error) (int, error) {
+	var summary exitSummary
+	for i := range codes {
+		result := "exit code " + strconv.Itoa(codes[i])
+		if errs[i] != nil {
+			result = fmt.Sprintf("exit code %d, err:%s", codes[i], errs[i])
+		}
+		fmt.Fprintf(w, "=== ecsfgrun: task %d/%d (%s=%d): %s ===\n", i+1, len(codes), indexEnvName, i, result)
+		summary.add(codes[i], codes[i] != 0 || errs[i] != nil)
+	}
+	if summary.failed > 0 {
+		return summary.code, fmt.Errorf("%d of %d tasks failed", summary.failed, len(codes))
+	}
+	return 0, nil
+}
+
+// exitSummary counts the failed runs. code is the exit code of the first failed run, or 1 if
+// that run failed with exit code 0, e.g. by an error before the task started.
+type exitSummary struct {
+	code   int
+	failed int
+}
+
+func (s *exitSummary) add(code int, failed bool) {
+	if !failed {
+		return
+	}
+	s.failed++
+	if s.code == 0 {
+		s.code = code
+		if s.code == 0 {
+			s.code = 1
+		}
+	}
+}
+
+// withIndex returns a copy of input whose container override has the index of the task.
+func withIndex(input *ecs.RunTaskInput, index, count int) *ecs.RunTaskInput {
+	res := *input
+	overrides := ecs.TaskOverride{}
+	if input.Overrides != nil {
+		overrides = *input.Overrides
+	}
+	containers := overrides.ContainerOverrides
+	overrides.ContainerOverrides = make([]*ecs.ContainerOverride, len(containers))
+	for i, o := range containers {
+		co := *o
+		if i == 0 {
+			co.Environment = append(append([]*ecs.KeyValuePair{}, o.Environment...),
+				&ecs.KeyValuePair{Name: aws.String(indexEnvName), Value: aws.String(strconv.Itoa(index))},
+				&ecs.KeyValuePair{Name: aws.String(countEnvName), Value: aws.String(strconv.Itoa(count))},
+			)
+		}
+		overrides.ContainerOverrides[i] = &co
+	}
+	res.Overrides = &overrides
+	return &res
+}
+
+// runTaskLimiter limits the number of RunTask calls in flight.
+type runTaskLimiter struct {
+	ecsiface.ECSAPI
+	sem chan struct{}
+}
+
+func (l *runTaskLimiter) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	l.sem <- struct{}{}
+	defer func() { <-l.sem }()
+	return l.ECSAPI.RunTask(input)
+}
+
+// prefixWriter writes each line with the prefix. Writers sharing mu do not mix their lines.
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	i := bytes.LastIndexByte(p.buf, '\n')
+	if i < 0 {
+		return len(b), nil
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(p.buf[:i+1], []byte("\n")) {
+		if len(line) > 0 {
+			out.WriteString(p.prefix)
+			out.Write(line)
+		}
+	}
+	p.buf = append([]byte{}, p.buf[i+1:]...)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush writes the last line which has no newline.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := p.Write([]byte("\n"))
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestWithIndex(t *testing.T) {
+	input := &ecs.RunTaskInput{Overrides: &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{{
+		Name:        aws.String("app"),
+		Environment: []*ecs.KeyValuePair{{Name: aws.String("MODE"), Value: aws.String("dev")}},
+	}}}}
+	res := withIndex(input, 3, 5)
+	envs := res.Overrides.ContainerOverrides[0].Environment
+	if v, _ := getEnv(envs, indexEnvName); v != "3" {
+		t.Errorf("withIndex() %s = %s, want:3", indexEnvName, v)
+	}
+	if v, _ := getEnv(envs, countEnvName); v != "5" {
+		t.Errorf("withIndex() %s = %s, want:5", countEnvName, v)
+	}
+	if len(input.Overrides.ContainerOverrides[0].Environment) != 1 {
+		t.Errorf("withIndex() modified input: %v", input.Overrides.ContainerOverrides[0].Environment)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var b bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{w: &b, mu: &mu, prefix: "[1] "}
+	w.Write([]byte("hoge\nfu"))   // nolint errcheck
+	w.Write([]byte("ga\n\npiyo")) // nolint errcheck
+	w.Flush()                     // nolint errcheck
+	expected := "[1] hoge\n[1] fuga\n[1] \n[1] piyo\n"
+	if b.String() != expected {
+		t.Errorf("prefixWriter = %q, want:%q", b.String(), expected)
+	}
+}
+
+func TestSummarizeFanout(t *testing.T) {
+	var b bytes.Buffer
+	code, err := summarizeFanout(&b, []int{0, 0}, []error{nil, nil})
+	if code != 0 || err != nil {
+		t.Errorf("summarizeFanout() = %d, %v, want:0, nil", code, err)
+	}
+	b.Reset()
+	code, err = summarizeFanout(&b, []int{0, 3, 1}, []error{nil, nil, errors.New("task not found")})
+	if code != 3 || err == nil || err.Error() != "2 of 3 tasks failed" {
+		t.Errorf("summarizeFanout() = %d, %v, want:3, 2 of 3 tasks failed", code, err)
+	}
+	if !strings.Contains(b.String(), "task 3/3 (ECSFGRUN_INDEX=2): exit code 1, err:task not found") {
+		t.Errorf("summarizeFanout() = %s", b.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+// runGC stops the tasks started by ecsfgrun which have been running longer than a threshold.
+// Without -stop it only lists them.
+func runGC(w io.Writer, client ecsiface.ECSAPI, env environments, args []string) (int, error) {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	all := fs.Bool("a", false, "include the tasks started by all users")
+	olderThan := fs.Duration("older-than", 24*time.Hour, "stop the tasks running longer than this")
+	stop := fs.Bool("stop", false, "stop the tasks. without this, the tasks are only listed")
+	if err := fs.Parse(args); err != nil {
+		return 1, err
+	}
+	if err := checkNoArgs(fs); err != nil {
+		return 1, err
+	}
+	startedBy := getStartedBy(env)
+	if *all {
+		startedBy = ""
+	}
+	tasks, err := listTasks(client, env.Cluster, startedBy)
+	if err != nil {
+		return 1, err
+	}
+	orphans := filterOlderTasks(tasks, *olderThan, time.Now())
+	printTasks(w, orphans, time.Now())
+	if !*stop {
+		if len(orphans) > 0 {
+			fmt.Fprintf(w, "%d tasks will be stopped with -stop\n", len(orphans))
+		}
+		return 0, nil
+	}
+	code := 0
+	for _, task := range orphans {
+		_, err := client.StopTask(&ecs.StopTaskInput{
+			Cluster: &env.Cluster,
+			Task:    task.TaskArn,
+			Reason:  aws.String(fmt.Sprintf("stopped by ecsfgrun gc: running longer than %s", *olderThan)),
+		})
+		if err != nil {
+			log.Printf("failed to stop task %s err:%s", getTaskID(task.TaskArn), err)
+			code = 1
+			continue
+		}
+		fmt.Fprintf(w, "stopped %s\n", getTaskID(task.TaskArn))
+	}
+	return code, nil
+}
+
+func filterOlderTasks(tasks []*ecs.Task, olderThan time.Duration, now time.Time) []*ecs.Task {
+	var res []*ecs.Task
+	for _, task := range tasks {
+		if task.CreatedAt != nil && now.Sub(*task.CreatedAt) > olderThan {
+			res = append(res, task)
+		}
+	}
+	return res
+}
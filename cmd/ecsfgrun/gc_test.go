@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+type stopTaskECS struct {
+	listTasksECS
+	stopped []string
+}
+
+func (m *stopTaskECS) StopTask(input *ecs.StopTaskInput) (*ecs.StopTaskOutput, error) {
+	m.stopped = append(m.stopped, aws.StringValue(input.Task))
+	return &ecs.StopTaskOutput{}, nil
+}
+
+func TestRunGC(t *testing.T) {
+	now := time.Now()
+	m := stopTaskECS{listTasksECS: listTasksECS{
+		pages: []ecs.ListTasksOutput{
+			{TaskArns: []*string{aws.String("a"), aws.String("b")}},
+		},
+		mockedECS: mockedECS{
+			dtresp: ecs.DescribeTasksOutput{
+				Tasks: []*ecs.Task{
+					{
+						TaskArn:   aws.String("arn:aws:ecs:us-east-1:123456789012:task/hoge/old"),
+						StartedBy: aws.String("ecsfgrun/hoge"),
+						CreatedAt: aws.Time(now.Add(-48 * time.Hour)),
+					},
+					{
+						TaskArn:   aws.String("arn:aws:ecs:us-east-1:123456789012:task/hoge/new"),
+						StartedBy: aws.String("ecsfgrun/hoge"),
+						CreatedAt: aws.Time(now.Add(-time.Hour)),
+					},
+				},
+			},
+		},
+	}}
+	var b bytes.Buffer
+	code, err := runGC(&b, &m, environments{}, nil)
+	if code != 0 || err != nil {
+		t.Fatalf("runGC() = %d, err:%v", code, err)
+	}
+	if len(m.stopped) != 0 {
+		t.Errorf("runGC() stopped = %v without -stop", m.stopped)
+	}
+	if !strings.Contains(b.String(), "old") || strings.Contains(b.String(), "new") {
+		t.Errorf("runGC() = %s", b.String())
+	}
+
+	b.Reset()
+	code, err = runGC(&b, &m, environments{}, []string{"-stop", "-older-than", "30m"})
+	if code != 0 || err != nil {
+		t.Fatalf("runGC() = %d, err:%v", code, err)
+	}
+	if len(m.stopped) != 2 {
+		t.Errorf("runGC() stopped = %v, want 2 tasks", m.stopped)
+	}
+
+	if _, err := runGC(&b, &m, environments{}, []string{"-stop", "now"}); err == nil {
+		t.Error("runGC(now) err:nil, want error")
+	}
+}
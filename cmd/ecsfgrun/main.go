@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -36,23 +38,71 @@ const (
 )
 
 type environments struct {
-	AWSSharedCredentialsFile string        `envconfig:"AWS_SHARED_CREDENTIALS_FILE"`
-	AWSConfigFile            string        `envconfig:"AWS_CONFIG_FILE"`
-	AWSDefaultProfile        string        `envconfig:"AWS_DEFAULT_PROFILE"`
-	AWSProfile               string        `envconfig:"AWS_PROFILE"`
-	AWSDefaultRegion         string        `envconfig:"AWS_DEFAULT_REGION"`
-	AWSRegion                string        `envconfig:"AWS_REGION"`
-	OverrideEnvPrefix        string        `envconfig:"OVERRIDE_ENV_PREFIX" default:"ECSFGRUN_"`
-	Home                     string        `envconfig:"HOME"`
-	StartWait                time.Duration `envconfig:"START_WAIT" default:"40s"`
-	ShowPending              bool          `envconfig:"SHOW_PENDING" default:"false"`
-	PrintTime                bool          `envconfig:"PRINT_TIME" default:"false"`
-	AssignPublicIP           bool          `envconfig:"PUBLICIP" default:"true"`
-	Cluster                  string        `envconfig:"CLUSTER" desc:"If you do not specify a cluster, the default cluster is assumed"`
-	LaunchType               string        `envconfig:"LAUNCHTYPE" default:"FARGATE"`
-	SecurityGroups           []string      `envconfig:"SECGROUPS" desc:"Security groups of awsvpc network mode"`
-	Subnets                  []string      `envconfig:"SUBNETS" desc:"Subnets of awsvpc network mode"`
-	TaskDefinition           string        `envconfig:"TASKDEF" required:"false" desc:"The family and revision (family:revision ) or full ARN of the task definition to run."`
+	AWSSharedCredentialsFile string         `envconfig:"AWS_SHARED_CREDENTIALS_FILE"`
+	AWSConfigFile            string         `envconfig:"AWS_CONFIG_FILE"`
+	AWSDefaultProfile        string         `envconfig:"AWS_DEFAULT_PROFILE"`
+	AWSProfile               string         `envconfig:"AWS_PROFILE"`
+	AWSDefaultRegion         string         `envconfig:"AWS_DEFAULT_REGION"`
+	AWSRegion                string         `envconfig:"AWS_REGION"`
+	OverrideEnvPrefix        string         `envconfig:"OVERRIDE_ENV_PREFIX" default:"ECSFGRUN_"`
+	Home                     string         `envconfig:"HOME"`
+	StartWait                time.Duration  `envconfig:"START_WAIT" default:"40s"`
+	ShowPending              bool           `envconfig:"SHOW_PENDING" default:"false"`
+	PrintTime                bool           `envconfig:"PRINT_TIME" default:"false"`
+	AssignPublicIP           bool           `envconfig:"PUBLICIP" default:"true"`
+	Cluster                  string         `envconfig:"CLUSTER" desc:"If you do not specify a cluster, the default cluster is assumed"`
+	LaunchType               string         `envconfig:"LAUNCHTYPE" default:"FARGATE"`
+	CapacityProviders        []string       `envconfig:"CAPACITY_PROVIDERS" desc:"Capacity provider strategy used instead of LAUNCHTYPE (e.g. FARGATE_SPOT:3:base=0,FARGATE:1). \"default\" uses the default strategy of the cluster"`
+	SecurityGroups           []string       `envconfig:"SECGROUPS" desc:"Security groups of awsvpc network mode"`
+	Subnets                  []string       `envconfig:"SUBNETS" desc:"Subnets of awsvpc network mode"`
+	LikeService              string         `envconfig:"LIKE_SERVICE" desc:"Run with the cluster, network, capacity and task definition of this service"`
+	TaskDefinition           string         `envconfig:"TASKDEF" required:"false" desc:"The family and revision (family:revision ) or full ARN of the task definition to run."`
+	PlatformVersion          string         `envconfig:"PLATFORM_VERSION" desc:"Fargate platform version (e.g. 1.4.0). If not specified, LATEST is used"`
+	EphemeralStorage         int64          `envconfig:"EPHEMERAL_STORAGE" desc:"Ephemeral storage size (GiB, 21-200) of the Fargate task"`
+	CPUArchitecture          string         `envconfig:"CPU_ARCHITECTURE" desc:"Expected cpu architecture of the task definition (X86_64|ARM64)"`
+	TaskCPU                  string         `envconfig:"TASK_CPU" desc:"Task level cpu override (e.g. 1024 or 1 vCPU)"`
+	TaskMemory               string         `envconfig:"TASK_MEMORY" desc:"Task level memory override (e.g. 2048 or 2 GB)"`
+	TaskRoleARN              string         `envconfig:"TASK_ROLE_ARN" desc:"IAM role ARN override for the task"`
+	ExecutionRoleARN         string         `envconfig:"EXECUTION_ROLE_ARN" desc:"Task execution IAM role ARN override"`
+	ContainerCPU             int64          `envconfig:"CONTAINER_CPU" desc:"Cpu units override of the container"`
+	ContainerMemory          int64          `envconfig:"CONTAINER_MEMORY" desc:"Hard memory limit (MiB) override of the container"`
+	ContainerMemoryReserve   int64          `envconfig:"CONTAINER_MEMORY_RESERVATION" desc:"Soft memory limit (MiB) override of the container"`
+	TaskDefinitionFile       string         `envconfig:"TASKDEF_FILE" desc:"Task definition JSON file (the format of aws ecs register-task-definition --cli-input-json) to register and run. ${VAR} is replaced by the environment variable"`
+	CleanupTaskDefinition    bool           `envconfig:"CLEANUP_TASK_DEFINITION" default:"false" desc:"Deregister the task definition registered from TASKDEF_FILE after the run"`
+	Images                   []string       `envconfig:"IMAGES" desc:"Images (container=repository:tag) to run with a temporary task definition revision"`
+	DeleteTaskDefinition     bool           `envconfig:"DELETE_TASK_DEFINITION" default:"false" desc:"Delete the temporary task definition revision after deregistering it"`
+	ConfigFile               string         `envconfig:"CONFIG_FILE" desc:"Config file of ecsfgrun (default: ~/.ecsfgrun)"`
+	EnvFiles                 []string       `envconfig:"ENV_FILES" desc:"Dotenv files of the override environment. The later files take precedence"`
+	Envs                     []string       `envconfig:"ENVS" desc:"Override environment (KEY=VALUE). It takes precedence over the variables with OVERRIDE_ENV_PREFIX, which take precedence over ENV_FILES"`
+	S3EnvFiles               []string       `envconfig:"S3_ENV_FILES" desc:"ARNs of .env files in S3 which ECS loads into the container"`
+	Secrets                  []string       `envconfig:"SECRETS" desc:"Secrets (NAME=ssm:/path or NAME=secretsmanager:<arn>) resolved by ECS at launch. Override variables with these values are also secrets"`
+	Tags                     []string       `envconfig:"TAGS" desc:"Tags (key=value) of the task"`
+	AutoTags                 bool           `envconfig:"AUTO_TAGS" default:"false" desc:"Tag the task with the user, the host and the version of ecsfgrun"`
+	PropagateTags            string         `envconfig:"PROPAGATE_TAGS" desc:"Propagate the tags from TASK_DEFINITION or SERVICE to the task"`
+	EnableECSManagedTags     bool           `envconfig:"ENABLE_ECS_MANAGED_TAGS" default:"false" desc:"Enable Amazon ECS managed tags for the task"`
+	StartedBy                string         `envconfig:"STARTED_BY" desc:"startedBy of the task (default: ecsfgrun/<user>)"`
+	JobName                  string         `envconfig:"JOB_NAME" desc:"Job name. The task group is ecsfgrun:<job-name>"`
+	Group                    string         `envconfig:"GROUP" desc:"Task group. It overrides JOB_NAME"`
+	PlacementConstraints     semicolonsFlag `envconfig:"PLACEMENT_CONSTRAINTS" desc:"Placement constraints separated by ; (e.g. memberOf:attribute:ecs.instance-type =~ g4dn.*;distinctInstance)"`
+	PlacementStrategy        semicolonsFlag `envconfig:"PLACEMENT_STRATEGY" desc:"Placement strategy separated by ; (e.g. spread:attribute:ecs.availability-zone;binpack:memory)"`
+	RetryMaxAttempts         int            `envconfig:"RETRY_MAX_ATTEMPTS" default:"1" desc:"Maximum number of attempts when the task fails by a Spot interruption or a capacity shortage"`
+	RetryBackoff             time.Duration  `envconfig:"RETRY_BACKOFF" default:"10s" desc:"Wait before the first retry. It doubles on every retry"`
+	RetryOnDemand            bool           `envconfig:"RETRY_ON_DEMAND" default:"false" desc:"Replace FARGATE_SPOT with FARGATE when retrying"`
+	DryRun                   bool           `envconfig:"DRY_RUN" default:"false" desc:"Print the RunTask request and exit without running the task"`
+	DryRunFormat             string         `envconfig:"DRY_RUN_FORMAT" default:"json" desc:"Output format of dry-run (json|yaml)"`
+	LogDrainQuiet            time.Duration  `envconfig:"LOG_DRAIN_QUIET" default:"3s" desc:"After the task stops, read the logs until no new events arrive for this period"`
+	LogDrainTimeout          time.Duration  `envconfig:"LOG_DRAIN_TIMEOUT" default:"30s" desc:"Upper bound of reading the logs after the task stops"`
+	APIMaxAttempts           int            `envconfig:"API_MAX_ATTEMPTS" default:"8" desc:"Maximum number of attempts of an AWS API call failed by throttling or a transient error"`
+	APIRetryBase             time.Duration  `envconfig:"API_RETRY_BASE" default:"500ms" desc:"Wait before the first retry of an AWS API call. It doubles with jitter on every retry"`
+	APIRateLimit             float64        `envconfig:"API_RATE_LIMIT" default:"5" desc:"Maximum calls per second to each of ECS and CloudWatch Logs. 0 disables the limit"`
+	Count                    int            `envconfig:"COUNT" default:"1" desc:"Number of the tasks to run. Each task gets ECSFGRUN_INDEX (0 to COUNT-1) and ECSFGRUN_COUNT"`
+	CommandMode              string         `envconfig:"COMMAND_MODE" desc:"exec sends the command line as it is (default). shell runs it in SHELL_ENTRYPOINT"`
+	ShellEntrypoint          string         `envconfig:"SHELL_ENTRYPOINT" default:"/bin/sh -c" desc:"Shell which runs the command line in shell mode"`
+	Script                   string         `envconfig:"SCRIPT" desc:"Local script file to run in the container. The arguments are passed to the script"`
+	ScriptInterpreter        string         `envconfig:"SCRIPT_INTERPRETER" default:"sh -c" desc:"Shell which runs SCRIPT in the container (e.g. bash -c)"`
+	OverrideFallback         bool           `envconfig:"OVERRIDE_FALLBACK" default:"false" desc:"Move the command and environment into a temporary task definition revision when the overrides exceed 8192 bytes"`
+	Verbose                  bool           `envconfig:"VERBOSE" default:"false" desc:"Show the names of the override environment variables and secrets"`
+	MaskPattern              string         `envconfig:"MASK_PATTERN" default:"(?i)(secret|passw|token|key|credential|private)" desc:"Environment override names matching this pattern are masked in dry-run output"`
 }
 
 type profileConfig struct {
@@ -69,11 +119,58 @@ var (
 )
 
 func init() {
+	log.SetFlags(log.Lshortfile | log.LstdFlags)
+	err := envconfig.Process("", &env)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// flags take precedence over environment variables
 	showVersion := false
 	showHelp := false
+	shellMode := false
+	execMode := false
 	flag.BoolVar(&showVersion, "version", false, "show version")
 	flag.BoolVar(&showHelp, "h", false, "show help")
+	flag.BoolVar(&env.DryRun, "dry-run", env.DryRun, "print the RunTask request and exit without running the task")
+	flag.StringVar(&env.DryRunFormat, "dry-run-format", env.DryRunFormat, "output format of dry-run (json|yaml)")
+	flag.StringVar(&env.TaskCPU, "cpu", env.TaskCPU, "task level cpu override (e.g. 1024 or \"1 vCPU\")")
+	flag.StringVar(&env.TaskMemory, "memory", env.TaskMemory, "task level memory override (e.g. 2048 or \"2 GB\")")
+	flag.StringVar(&env.TaskRoleARN, "task-role-arn", env.TaskRoleARN, "IAM role ARN override for the task")
+	flag.StringVar(&env.ExecutionRoleARN, "execution-role-arn", env.ExecutionRoleARN, "task execution IAM role ARN override")
+	flag.Int64Var(&env.ContainerCPU, "container-cpu", env.ContainerCPU, "cpu units override of the container")
+	flag.Int64Var(&env.ContainerMemory, "container-memory", env.ContainerMemory, "hard memory limit (MiB) override of the container")
+	flag.Int64Var(&env.ContainerMemoryReserve, "container-memory-reservation", env.ContainerMemoryReserve, "soft memory limit (MiB) override of the container")
+	flag.StringVar(&env.LikeService, "like-service", env.LikeService, "run with the cluster, network, capacity and task definition of the service")
+	flag.StringVar(&env.JobName, "job-name", env.JobName, "job name. the task group is ecsfgrun:<job-name>")
+	flag.Var((*stringsFlag)(&env.Tags), "tag", "tag (key=value) of the task. can be repeated")
+	flag.Var(&env.PlacementConstraints, "placement-constraint", "placement constraint (memberOf:<expression> or distinctInstance). can be repeated")
+	flag.Var(&env.PlacementStrategy, "placement-strategy", "placement strategy (random, spread:<field> or binpack:<cpu|memory>). can be repeated")
+	flag.StringVar(&env.TaskDefinitionFile, "task-definition-file", env.TaskDefinitionFile, "register and run the task definition JSON file")
+	flag.BoolVar(&env.CleanupTaskDefinition, "cleanup-task-definition", env.CleanupTaskDefinition, "deregister the task definition registered from the file after the run")
+	flag.Var((*stringsFlag)(&env.Images), "image", "run with another image (container=repository:tag). can be repeated")
+	flag.IntVar(&env.Count, "count", env.Count, "number of the tasks to run. each task gets ECSFGRUN_INDEX and ECSFGRUN_COUNT")
+	flag.BoolVar(&shellMode, "shell", false, "run the command line in the shell of SHELL_ENTRYPOINT (default: /bin/sh -c)")
+	flag.BoolVar(&execMode, "exec", false, "send the command line as it is. subcommand names like ps are also taken as the command")
+	flag.StringVar(&env.ShellEntrypoint, "shell-entrypoint", env.ShellEntrypoint, "shell which runs the command line with -shell")
+	flag.StringVar(&env.Script, "script", env.Script, "run the local script file in the container. the arguments are passed to the script")
+	flag.StringVar(&env.ScriptInterpreter, "script-interpreter", env.ScriptInterpreter, "shell which runs the script in the container (e.g. \"bash -c\")")
+	flag.BoolVar(&env.OverrideFallback, "override-fallback", env.OverrideFallback, "move the command and environment into a temporary task definition revision when the overrides exceed 8192 bytes")
+	flag.BoolVar(&env.Verbose, "v", env.Verbose, "show the names of the override environment variables and secrets")
+	flag.Var((*stringsFlag)(&env.EnvFiles), "env-file", "dotenv file of the override environment. can be repeated")
+	flag.Var((*stringsFlag)(&env.Envs), "env", "override environment (KEY=VALUE). can be repeated")
+	flag.Var((*stringsFlag)(&env.S3EnvFiles), "s3-env-file", "ARN of a .env file in S3 which ECS loads into the container. can be repeated")
+	flag.Var((*stringsFlag)(&env.Secrets), "secret", "secret (NAME=ssm:/path or NAME=secretsmanager:<arn>) resolved by ECS at launch. can be repeated")
+	flag.BoolVar(&env.DeleteTaskDefinition, "delete-task-definition", env.DeleteTaskDefinition, "delete the temporary task definition revision after deregistering it")
 	flag.Parse()
+	switch {
+	case shellMode && execMode:
+		log.Fatal("-shell and -exec cannot be used together")
+	case shellMode:
+		env.CommandMode = commandModeShell
+	case execMode:
+		env.CommandMode = commandModeExec
+	}
 	if showVersion {
 		fmt.Printf("%s version %v, commit %v, built at %v\n", filepath.Base(os.Args[0]), version, commit, date)
 		os.Exit(0)
@@ -84,11 +181,6 @@ func init() {
 		os.Exit(0)
 	}
 
-	log.SetFlags(log.Lshortfile | log.LstdFlags)
-	err := envconfig.Process("", &env)
-	if err != nil {
-		log.Fatal(err)
-	}
 	if len(env.Home) == 0 {
 		env.Home, err = homedir.Dir()
 		if err != nil {
@@ -97,6 +189,18 @@ func init() {
 	}
 }
 
+// stringsFlag is a flag.Value which can be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func main() {
 	args := flag.Args()
 	sess := session.Must(session.NewSession())
@@ -104,7 +208,24 @@ func main() {
 	if err == nil && len(conf.SrcProfile) > 0 {
 		sess = getStsSession(conf)
 	}
-	code, err := run(ecs.New(sess), cloudwatchlogs.New(sess), env, args)
+	// the calls are retried by retryingECS and retryingLogs instead of the SDK
+	rand.Seed(time.Now().UnixNano())
+	noRetry := aws.NewConfig().WithMaxRetries(0)
+	ecsSv := newRetryingECS(ecs.New(sess, noRetry), env)
+	logsSv := newRetryingLogs(cloudwatchlogs.New(sess, noRetry), env)
+	var code int
+	switch {
+	case isSubcommand(args, env, "ps"):
+		code, err = runPs(os.Stdout, ecsSv, env, args[1:])
+	case isSubcommand(args, env, "gc"):
+		code, err = runGC(os.Stdout, ecsSv, env, args[1:])
+	case isSubcommand(args, env, "workflow"):
+		code, err = runWorkflow(os.Stdout, ecsSv, logsSv, env, args[1:])
+	case isSubcommand(args, env, "matrix"):
+		code, err = runMatrix(os.Stdout, ecsSv, logsSv, env, args[1:])
+	default:
+		code, err = run(os.Stdout, ecsSv, logsSv, env, args)
+	}
 	if err != nil {
 		log.Println(err)
 	}
@@ -119,17 +240,85 @@ func createStrSliceRef(s []string) []*string {
 	return res
 }
 
-func run(ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, env environments, cmdline []string) (int, error) {
-	input, err := createRunParam(ecsSv, env, cmdline)
+func run(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, env environments, cmdline []string) (int, error) {
+	env, client, reg, err := prepareRun(ecsSv, env, sendsOverrideEnv(env, cmdline))
 	if err != nil {
 		return 1, err
 	}
+	input, err := createRunParam(client, env, cmdline)
+	if err != nil {
+		return 1, err
+	}
+	if err := checkOverridesSize(input.Overrides); err != nil {
+		if !env.OverrideFallback {
+			return 1, err
+		}
+		if reg == nil {
+			if reg, err = describeRegisterParam(ecsSv, env.TaskDefinition); err != nil {
+				return 1, err
+			}
+		}
+		log.Printf("the overrides are %d bytes: move the command and environment into a temporary task definition revision", overridesSize(input.Overrides))
+		moveOverridesToTaskDefinition(input.Overrides, reg)
+		if err := checkOverridesSize(input.Overrides); err != nil {
+			return 1, err
+		}
+	}
+	if env.DryRun {
+		return 0, printDryRun(w, client, input, reg, env)
+	}
+	if reg != nil {
+		arn, err := registerTaskDefinition(ecsSv, reg)
+		if err != nil {
+			return 1, err
+		}
+		if len(env.TaskDefinitionFile) == 0 || env.CleanupTaskDefinition {
+			defer scheduleCleanup(ecsSv, arn, env)()
+		}
+		input.TaskDefinition = &arn
+	}
+	if env.Count > 1 {
+		return runFanout(w, ecsSv, logsSv, input, env)
+	}
+	return runWithRetry(w, ecsSv, logsSv, input, env)
+}
+
+// prepareRun resolves the settings and the temporary task definition revision, then validates them.
+// The returned client describes the revision before it is registered. withOverrideVars tells
+// whether the secret references of the override environment are used.
+func prepareRun(ecsSv ecsiface.ECSAPI, env environments, withOverrideVars bool) (environments, ecsiface.ECSAPI, *ecs.RegisterTaskDefinitionInput, error) {
+	if len(env.LikeService) > 0 {
+		var err error
+		if env, err = applyLikeService(ecsSv, env); err != nil {
+			return env, nil, nil, err
+		}
+	}
+	reg, err := createRegisterParam(ecsSv, env, withOverrideVars)
+	if err != nil {
+		return env, nil, nil, err
+	}
+	client := ecsSv
+	if reg != nil {
+		// describe the new revision before it is registered
+		client = &localTaskDefinition{ECSAPI: ecsSv, def: getTaskDefinitionOf(reg)}
+		env.TaskDefinition = aws.StringValue(reg.Family)
+	}
+	if err := validateRunParam(client, env); err != nil {
+		return env, nil, nil, err
+	}
+	return env, client, reg, nil
+}
+
+func runTask(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, input *ecs.RunTaskInput, env environments, attempt int) (int, error) {
 	task, err := runContainer(ecsSv, input)
 	if err != nil {
 		return 1, err
 	}
-	logGroup := "/ecs/" + getGroupID(env.TaskDefinition)
-	logStream := "ecs/" + aws.StringValue(task.Containers[0].Name) + "/" + getTaskID(task.TaskArn)
+	if env.RetryMaxAttempts > 1 {
+		fmt.Fprintf(w, "=== ecsfgrun: attempt %d/%d, task %s ===\n", attempt, env.RetryMaxAttempts, getTaskID(task.TaskArn))
+	}
+	logGroup := getLogGroup(env.TaskDefinition)
+	logStream := getLogStream(aws.StringValue(task.Containers[0].Name), getTaskID(task.TaskArn))
 
 	logReq := cloudwatchlogs.GetLogEventsInput{
 		LogGroupName:  &logGroup,
@@ -141,48 +330,91 @@ func run(ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, en
 		Cluster: &env.Cluster,
 		Tasks:   []*string{aws.String(getTaskID(task.TaskArn))},
 	}
-	return readLog(os.Stdout, logsSv, ecsSv, logReq, ecsReq, env)
+	return readLog(w, logsSv, ecsSv, logReq, ecsReq, env)
 }
 
 func createRunParam(client ecsiface.ECSAPI, env environments, cmdline []string) (*ecs.RunTaskInput, error) {
-	assignPublicIP := "DISABLED"
-	if env.AssignPublicIP {
-		assignPublicIP = "ENABLED"
+	definition, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: &env.TaskDefinition})
+	if err != nil {
+		return nil, err
+	}
+	if definition.TaskDefinition == nil {
+		return nil, fmt.Errorf("task definition %s is not found", env.TaskDefinition)
+	}
+	networkConfiguration, warnings := createNetworkConfiguration(definition.TaskDefinition, env)
+	for _, warning := range warnings {
+		log.Printf("warning: %s", warning)
 	}
 	input := ecs.RunTaskInput{
-		NetworkConfiguration: &ecs.NetworkConfiguration{
-			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
-				AssignPublicIp: &assignPublicIP,
-				SecurityGroups: createStrSliceRef(env.SecurityGroups),
-				Subnets:        createStrSliceRef(env.Subnets),
-			},
-		},
-		LaunchType:     &env.LaunchType,
-		TaskDefinition: &env.TaskDefinition,
-		Cluster:        &env.Cluster,
-	}
-	if env.LaunchType == "EC2" {
-		input.NetworkConfiguration = nil
-	}
-	if len(cmdline) > 0 {
-		definition, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: &env.TaskDefinition})
+		NetworkConfiguration: networkConfiguration,
+		LaunchType:           &env.LaunchType,
+		TaskDefinition:       &env.TaskDefinition,
+		Cluster:              &env.Cluster,
+	}
+	if useCapacityProviders(env) {
+		strategy, err := parseCapacityProviders(env.CapacityProviders)
 		if err != nil {
 			return nil, err
 		}
-		var containerName *string
-		for _, container := range definition.TaskDefinition.ContainerDefinitions {
-			containerName = container.Name
+		input.LaunchType = nil
+		input.CapacityProviderStrategy = strategy
+	}
+	if len(env.PlatformVersion) > 0 {
+		input.PlatformVersion = &env.PlatformVersion
+	}
+	constraints, err := parsePlacementConstraints(env.PlacementConstraints)
+	if err != nil {
+		return nil, err
+	}
+	input.PlacementConstraints = constraints
+	strategy, err := parsePlacementStrategy(env.PlacementStrategy)
+	if err != nil {
+		return nil, err
+	}
+	input.PlacementStrategy = strategy
+	input.StartedBy = aws.String(getStartedBy(env))
+	if group := getGroup(env); len(group) > 0 {
+		input.Group = &group
+	}
+	tags, err := getTags(env)
+	if err != nil {
+		return nil, err
+	}
+	input.Tags = tags
+	if len(env.PropagateTags) > 0 {
+		input.PropagateTags = &env.PropagateTags
+	}
+	if env.EnableECSManagedTags {
+		input.EnableECSManagedTags = aws.Bool(true)
+	}
+	// the tasks of COUNT get their index through the container override
+	if sendsOverrideEnv(env, cmdline) || hasContainerResourceOverride(env) || env.Count > 1 {
+		override := &ecs.ContainerOverride{Name: getTargetContainer(definition.TaskDefinition)}
+		if sendsOverrideEnv(env, cmdline) {
+			override.Environment, err = getOverrideEnvs(env)
+			if err != nil {
+				return nil, err
+			}
+			override.EnvironmentFiles = getEnvironmentFiles(env)
+		}
+		if len(env.Script) > 0 {
+			command, script, err := createScriptCmd(env.Script, env.ScriptInterpreter, cmdline)
+			if err != nil {
+				return nil, err
+			}
+			override.Command = command
+			override.Environment = append(override.Environment, script)
+		} else if len(cmdline) > 0 {
+			if override.Command, err = createCommand(env, cmdline); err != nil {
+				return nil, err
+			}
 		}
+		applyContainerResourceOverride(override, env)
 		input.Overrides = &ecs.TaskOverride{
-			ContainerOverrides: []*ecs.ContainerOverride{
-				{
-					Command:     createCmd(cmdline),
-					Environment: makeEnvs(env.OverrideEnvPrefix),
-					Name:        containerName,
-				},
-			},
+			ContainerOverrides: []*ecs.ContainerOverride{override},
 		}
 	}
+	applyTaskOverride(&input, env)
 	return &input, nil
 }
 
@@ -206,31 +438,32 @@ func makeEnvs(prefix string) []*ecs.KeyValuePair {
 	return nil
 }
 
+// readLog polls the status of the task and tails its logs in separate goroutines until the task stops.
 func readLog(w io.Writer, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, ecsSv ecsiface.ECSAPI, logReq cloudwatchlogs.GetLogEventsInput, ecsReq ecs.DescribeTasksInput, env environments) (int, error) {
 	time.Sleep(env.StartWait)
-	for {
-		c, err := getContainerInfo(ecsSv, &ecsReq)
-		//pp.Println("containerInfo:", c)
-		if err != nil {
-			return 2, err
-		}
-		time.Sleep(3 * time.Second)
-		if aws.StringValue(c.LastStatus) == "PENDING" {
-			if env.ShowPending {
-				log.Printf("Task Status: %s", *c.LastStatus)
-			}
-			continue
-		}
-		next, err := getLogs(logsSv, w, logReq, env)
-		if err != nil {
-			log.Printf("getLogs err:%s", err)
-		}
-		if aws.StringValue(c.LastStatus) == "STOPPED" {
-			return int(aws.Int64Value(c.ExitCode)), nil
-		}
-		logReq.NextToken = next
-	}
-
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	result := make(chan taskStatus, 1)
+	tailDone := make(chan struct{})
+	go pollStatus(ctx, ecsSv, ecsReq, env, started, result)
+	go func() {
+		defer close(tailDone)
+		tailLogs(ctx, w, logsSv, logReq, env, started, stopped)
+	}()
+	status := <-result
+	if status.err != nil {
+		cancel()
+		<-tailDone
+		return 2, status.err
+	}
+	close(stopped)
+	<-tailDone
+	if isInfraStop(status.task) {
+		return 1, &taskStoppedError{status.task}
+	}
+	return int(aws.Int64Value(status.container.ExitCode)), nil
 }
 
 func createCmd(line []string) []*string {
@@ -344,8 +577,8 @@ func runContainer(client ecsiface.ECSAPI, input *ecs.RunTaskInput) (*ecs.Task, e
 	if err != nil {
 		return nil, err
 	}
-	for _, task := range res.Failures {
-		return nil, errors.New(task.String())
+	for _, failure := range res.Failures {
+		return nil, &runTaskFailure{failure}
 	}
 	for _, task := range res.Tasks {
 		if task == nil {
@@ -358,33 +591,70 @@ func runContainer(client ecsiface.ECSAPI, input *ecs.RunTaskInput) (*ecs.Task, e
 
 var errTaskNotFound = errors.New("task not found")
 
+// runTaskFailure is a failure reported by RunTask.
+type runTaskFailure struct {
+	failure *ecs.Failure
+}
+
+func (e *runTaskFailure) Error() string {
+	return e.failure.String()
+}
+
 func getContainerInfo(client ecsiface.ECSAPI, input *ecs.DescribeTasksInput) (*ecs.Container, error) {
+	_, container, err := getTaskInfo(client, input)
+	return container, err
+}
+
+func getTaskInfo(client ecsiface.ECSAPI, input *ecs.DescribeTasksInput) (*ecs.Task, *ecs.Container, error) {
 	res, err := client.DescribeTasks(input)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(res.Failures) != 0 {
-		return nil, errTaskNotFound
+		return nil, nil, errTaskNotFound
 	}
 	if res.Tasks == nil {
-		return nil, errTaskNotFound
+		return nil, nil, errTaskNotFound
 	}
 	for _, task := range res.Tasks {
 		for i := range task.Containers {
 			if task.Containers[i] == nil {
 				continue
 			}
-			return task.Containers[i], nil
+			return task, task.Containers[i], nil
 		}
 	}
-	return nil, errTaskNotFound
+	return nil, nil, errTaskNotFound
 }
 
 var (
-	taskIDRe = regexp.MustCompile("task/([^/]+)$")
+	taskIDRe = regexp.MustCompile("task/(?:[^/]+/)?([^/]+)$")
 )
 
+// getTargetContainer returns the name of the container that receives the overrides.
+func getTargetContainer(def *ecs.TaskDefinition) *string {
+	var containerName *string
+	if def == nil {
+		return containerName
+	}
+	for _, container := range def.ContainerDefinitions {
+		containerName = container.Name
+	}
+	return containerName
+}
+
+func getLogGroup(taskDef string) string {
+	return "/ecs/" + getGroupID(taskDef)
+}
+
+func getLogStream(containerName, taskID string) string {
+	return "ecs/" + containerName + "/" + taskID
+}
+
 func getGroupID(TaskDefName string) string {
+	if i := strings.LastIndex(TaskDefName, "task-definition/"); i >= 0 {
+		TaskDefName = TaskDefName[i+len("task-definition/"):]
+	}
 	return strings.SplitN(TaskDefName, ":", 2)[0]
 }
 
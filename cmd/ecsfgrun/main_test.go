@@ -475,6 +475,10 @@ func TestGetTaskID(t *testing.T) {
 			"arn:aws:ecs:us-east-1:954586889057:task/305b887f-2881-6b26-a443-6441f4443b73",
 			"305b887f-2881-6b26-a443-6441f4443b73",
 		},
+		{
+			"arn:aws:ecs:us-east-1:954586889057:task/default/305b887f28816b26a4436441f4443b73",
+			"305b887f28816b26a4436441f4443b73",
+		},
 	}
 	for _, vt := range vtests {
 		res := getTaskID(aws.String(vt.input))
@@ -522,6 +526,7 @@ func TestGetGroupID(t *testing.T) {
 		{"hoge:latest", "hoge"},
 		{"aaa:1", "aaa"},
 		{"fuga", "fuga"},
+		{"arn:aws:ecs:us-east-1:123456789012:task-definition/hoge:3", "hoge"},
 	}
 	for _, vt := range vtests {
 		res := getGroupID(vt.input)
@@ -594,6 +599,10 @@ func TestRun(t *testing.T) {
 			},
 			ecs.DescribeTaskDefinitionOutput{
 				TaskDefinition: &ecs.TaskDefinition{
+					RequiresCompatibilities: []*string{aws.String("FARGATE")},
+					NetworkMode:             aws.String("awsvpc"),
+					Cpu:                     aws.String("256"),
+					Memory:                  aws.String("512"),
 					ContainerDefinitions: []*ecs.ContainerDefinition{
 						&ecs.ContainerDefinition{
 							Name: aws.String("hoge"),
@@ -645,6 +654,10 @@ func TestRun(t *testing.T) {
 			},
 			ecs.DescribeTaskDefinitionOutput{
 				TaskDefinition: &ecs.TaskDefinition{
+					RequiresCompatibilities: []*string{aws.String("FARGATE")},
+					NetworkMode:             aws.String("awsvpc"),
+					Cpu:                     aws.String("256"),
+					Memory:                  aws.String("512"),
 					ContainerDefinitions: []*ecs.ContainerDefinition{
 						&ecs.ContainerDefinition{
 							Name: aws.String("hoge"),
@@ -696,6 +709,10 @@ func TestRun(t *testing.T) {
 			},
 			ecs.DescribeTaskDefinitionOutput{
 				TaskDefinition: &ecs.TaskDefinition{
+					RequiresCompatibilities: []*string{aws.String("FARGATE")},
+					NetworkMode:             aws.String("awsvpc"),
+					Cpu:                     aws.String("256"),
+					Memory:                  aws.String("512"),
 					ContainerDefinitions: []*ecs.ContainerDefinition{
 						&ecs.ContainerDefinition{
 							Name: aws.String("hoge"),
@@ -710,6 +727,10 @@ func TestRun(t *testing.T) {
 		},
 	}
 	env.StartWait = 0
+	env.LogDrainQuiet = 0
+	env.TaskDefinition = "hoge:1"
+	env.LaunchType = "FARGATE"
+	env.Subnets = []string{"subnet-00000000"}
 	for i, vt := range vtests {
 		tm := mockedECS{
 			dtresp:  vt.dtresp,
@@ -722,7 +743,7 @@ func TestRun(t *testing.T) {
 			err:  vt.lerr,
 		}
 
-		code, err := run(&tm, &lm, env, vt.args)
+		code, err := run(os.Stdout, &tm, &lm, env, vt.args)
 		if err != vt.err {
 			t.Errorf("err %d:run() = err:%s, want:%s", i, err, vt.err)
 		}
@@ -772,3 +793,24 @@ func TestGetStsSession(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateRunParamFargateOptions(t *testing.T) {
+	e := environments{
+		LaunchType:       "FARGATE",
+		Subnets:          []string{"subnet-1"},
+		TaskDefinition:   "hoge:1",
+		PlatformVersion:  "1.4.0",
+		EphemeralStorage: 50,
+	}
+	m := mockedECS{dtdresp: ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{NetworkMode: aws.String("awsvpc")}}}
+	input, err := createRunParam(&m, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(input.PlatformVersion) != "1.4.0" {
+		t.Errorf("createRunParam() PlatformVersion = %v, want:1.4.0", input.PlatformVersion)
+	}
+	if input.Overrides == nil || aws.Int64Value(input.Overrides.EphemeralStorage.SizeInGiB) != 50 {
+		t.Errorf("createRunParam() Overrides = %v, want EphemeralStorage:50", input.Overrides)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+// matrixRow is a row of the parameter file.
+type matrixRow map[string]string
+
+// matrixResult is the result of a row.
+type matrixResult struct {
+	taskArn  string
+	code     int
+	err      error
+	attempts int
+}
+
+// runMatrix runs a task for each row of the parameter file. The values of a row are passed as
+// the override environment, and the command line is a template like "backfill --date {{.date}}".
+func runMatrix(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, env environments, args []string) (int, error) {
+	fs := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	params := fs.String("params", "", "parameter file (.csv with a header line, or .jsonl)")
+	concurrency := fs.Int("concurrency", 4, "number of the tasks running at once")
+	retries := fs.Int("retries", 0, "number of the retries of a failed row. the wait starts at RETRY_BACKOFF and doubles")
+	if err := fs.Parse(args); err != nil {
+		return 1, err
+	}
+	if len(*params) == 0 {
+		return 1, fmt.Errorf("-params is required")
+	}
+	if *concurrency < 1 {
+		return 1, fmt.Errorf("-concurrency must be 1 or more")
+	}
+	if env.Count > 1 {
+		return 1, fmt.Errorf("COUNT=%d cannot be used with matrix: each row runs one task", env.Count)
+	}
+	rows, err := loadParams(*params)
+	if err != nil {
+		return 1, err
+	}
+	templates, err := parseCommandTemplates(fs.Args())
+	if err != nil {
+		return 1, err
+	}
+	// every row sends its values in the override environment
+	env, client, reg, err := prepareRun(ecsSv, env, true)
+	if err != nil {
+		return 1, err
+	}
+	inputs := make([]*ecs.RunTaskInput, len(rows))
+	for i, row := range rows {
+		if inputs[i], err = createMatrixParam(client, env, templates, row); err != nil {
+			return 1, fmt.Errorf("row %d: %s", i+1, err)
+		}
+	}
+	if env.DryRun {
+		for _, input := range inputs {
+			if err := printDryRun(w, client, input, reg, env); err != nil {
+				return 1, err
+			}
+		}
+		return 0, nil
+	}
+	if reg != nil {
+		arn, err := registerTaskDefinition(ecsSv, reg)
+		if err != nil {
+			return 1, err
+		}
+		if len(env.TaskDefinitionFile) == 0 || env.CleanupTaskDefinition {
+			defer scheduleCleanup(ecsSv, arn, env)()
+		}
+		for _, input := range inputs {
+			input.TaskDefinition = &arn
+		}
+	}
+	results := make([]matrixResult, len(rows))
+	sem := make(chan struct{}, *concurrency)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := range inputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			pw := &prefixWriter{w: w, mu: &mu, prefix: fmt.Sprintf("[row %d] ", i+1)}
+			results[i] = runMatrixRow(pw, ecsSv, logsSv, inputs[i], env, *retries)
+			pw.Flush() // nolint errcheck
+		}(i)
+	}
+	wg.Wait()
+	return printMatrixResults(w, rows, results)
+}
+
+// runMatrixRow runs the task of a row until it succeeds or the retries are used up.
+// A row which exits with a non-zero code is retried, but an error is retried only if it is retryable.
+func runMatrixRow(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, input *ecs.RunTaskInput, env environments, retries int) matrixResult {
+	client := &taskRecorder{ECSAPI: ecsSv}
+	var res matrixResult
+	retryWithBackoff(env.RetryBackoff, retries+1, func(attempt int) (bool, string) {
+		res.code, res.err = runWithRetry(w, client, logsSv, input, env)
+		res.taskArn = client.taskArn
+		res.attempts = attempt
+		if res.err != nil {
+			return isRetryable(res.err), res.err.Error()
+		}
+		if res.code == 0 {
+			return false, ""
+		}
+		return true, fmt.Sprintf("row failed with exit code %d", res.code)
+	})
+	return res
+}
+
+// createMatrixParam returns the RunTask input of the row.
+func createMatrixParam(client ecsiface.ECSAPI, env environments, templates []*template.Template, row matrixRow) (*ecs.RunTaskInput, error) {
+	cmdline := make([]string, len(templates))
+	for i, t := range templates {
+		var b bytes.Buffer
+		if err := t.Execute(&b, row); err != nil {
+			return nil, err
+		}
+		cmdline[i] = b.String()
+	}
+	names := make([]string, 0, len(row))
+	for name := range row {
+		if !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid parameter name %q: use letters, numbers and _", name)
+		}
+		// the secrets are in the task definition revision, which is shared by all the rows
+		if isSecretRef(row[name]) {
+			return nil, fmt.Errorf("the value of %s is a secret reference: secrets cannot differ by row. use SECRETS for all the rows", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// the values of the row take precedence over ENVS
+	env.Envs = append([]string{}, env.Envs...)
+	for _, name := range names {
+		env.Envs = append(env.Envs, name+"="+row[name])
+	}
+	input, err := createRunParam(client, env, cmdline)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOverridesSize(input.Overrides); err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+func parseCommandTemplates(cmdline []string) ([]*template.Template, error) {
+	res := make([]*template.Template, len(cmdline))
+	for i, arg := range cmdline {
+		t, err := template.New(fmt.Sprintf("arg%d", i)).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command template %q: %s", arg, err)
+		}
+		res[i] = t
+	}
+	return res, nil
+}
+
+// loadParams reads the rows of a CSV file with a header line, or a JSON Lines file of objects.
+func loadParams(path string) ([]matrixRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint errcheck
+	var rows []matrixRow
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = readCSVParams(f)
+	case ".jsonl", ".ndjson":
+		rows, err = readJSONLParams(f)
+	default:
+		return nil, fmt.Errorf("%s: the parameter file must be .csv or .jsonl", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no rows", path)
+	}
+	return rows, nil
+}
+
+func readCSVParams(r io.Reader) ([]matrixRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]matrixRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := matrixRow{}
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSONLParams(r io.Reader) ([]matrixRow, error) {
+	var rows []matrixRow
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &values); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+		row := matrixRow{}
+		for k, v := range values {
+			switch v := v.(type) {
+			case string:
+				row[k] = v
+			case nil:
+				row[k] = ""
+			default:
+				b, _ := json.Marshal(v) // nolint errcheck
+				row[k] = string(b)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// printMatrixResults prints the table of row, task and exit code, and returns the first failure.
+func printMatrixResults(w io.Writer, rows []matrixRow, results []matrixResult) (int, error) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROW\tPARAMS\tTASK ARN\tATTEMPTS\tEXIT CODE\tERROR")
+	var summary exitSummary
+	for i, res := range results {
+		errMsg := ""
+		if res.err != nil {
+			errMsg = strings.Replace(res.err.Error(), "\n", " ", -1)
+		}
+		taskArn := res.taskArn
+		if len(taskArn) == 0 {
+			taskArn = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%s\n", i+1, formatRow(rows[i]), taskArn, res.attempts, res.code, errMsg)
+		summary.add(res.code, res.code != 0 || res.err != nil)
+	}
+	tw.Flush() // nolint errcheck
+	if summary.failed > 0 {
+		return summary.code, fmt.Errorf("%d of %d rows failed", summary.failed, len(results))
+	}
+	return 0, nil
+}
+
+func formatRow(row matrixRow) string {
+	names := make([]string, 0, len(row))
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	kvs := make([]string, len(names))
+	for i, name := range names {
+		kvs[i] = name + "=" + row[name]
+	}
+	return strings.Join(kvs, ",")
+}
+
+// taskRecorder remembers the ARN of the last task started by RunTask.
+type taskRecorder struct {
+	ecsiface.ECSAPI
+	taskArn string
+}
+
+func (r *taskRecorder) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	res, err := r.ECSAPI.RunTask(input)
+	if err == nil && res != nil {
+		for _, task := range res.Tasks {
+			if task != nil {
+				r.taskArn = aws.StringValue(task.TaskArn)
+				break
+			}
+		}
+	}
+	return res, err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestLoadParams(t *testing.T) {
+	var vtests = []struct {
+		path     string
+		expected []matrixRow
+		err      bool
+	}{
+		{"./test/params.csv", []matrixRow{{"date": "2024-01-01", "tenant": "acme"}, {"date": "2024-01-02", "tenant": "foo, inc"}}, false},
+		{"./test/params.jsonl", []matrixRow{{"date": "2024-01-01", "tenant": "acme", "limit": "10"}, {"date": "2024-01-02", "tenant": ""}}, false},
+		{"./test/taskdef.json", nil, true},
+		{"./test/none.csv", nil, true},
+	}
+	for i, vt := range vtests {
+		rows, err := loadParams(vt.path)
+		if (err != nil) != vt.err {
+			t.Errorf("err %d:loadParams() = err:%v, want err:%v", i, err, vt.err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, vt.expected) {
+			t.Errorf("err %d:loadParams() = %v, want:%v", i, rows, vt.expected)
+		}
+	}
+}
+
+func TestCreateMatrixParam(t *testing.T) {
+	m := mockedECS{
+		dtdresp: ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{
+				NetworkMode:          aws.String("awsvpc"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("app")}},
+			},
+		},
+	}
+	e := environments{LaunchType: "FARGATE", Subnets: []string{"subnet-1"}, TaskDefinition: "hoge:1", Envs: []string{"MODE=prod", "tenant=none"}}
+	templates, err := parseCommandTemplates([]string{"backfill", "--date={{.date}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := createMatrixParam(&m, e, templates, matrixRow{"date": "2024-01-01", "tenant": "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := input.Overrides.ContainerOverrides[0]
+	if strings.Join(aws.StringValueSlice(o.Command), " ") != "backfill --date=2024-01-01" {
+		t.Errorf("createMatrixParam() Command = %v", aws.StringValueSlice(o.Command))
+	}
+	for k, expected := range map[string]string{"MODE": "prod", "date": "2024-01-01", "tenant": "acme"} {
+		if v, _ := getEnv(o.Environment, k); v != expected {
+			t.Errorf("createMatrixParam() %s = %s, want:%s", k, v, expected)
+		}
+	}
+	if len(e.Envs) != 2 {
+		t.Errorf("createMatrixParam() modified ENVS: %v", e.Envs)
+	}
+	templates, _ = parseCommandTemplates([]string{"{{.none}}"}) // nolint errcheck
+	if _, err := createMatrixParam(&m, e, templates, matrixRow{"date": "2024-01-01"}); err == nil {
+		t.Error("createMatrixParam() with a missing key = nil, want error")
+	}
+	templates, _ = parseCommandTemplates([]string{"backfill"}) // nolint errcheck
+	if _, err := createMatrixParam(&m, e, templates, matrixRow{"DB_PASSWORD": "ssm:/prod/db"}); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD is a secret reference") {
+		t.Errorf("createMatrixParam() with a secret reference = %v, want error", err)
+	}
+	if _, err := parseCommandTemplates([]string{"{{.date"}); err == nil {
+		t.Error("parseCommandTemplates() = nil, want error")
+	}
+}
+
+func TestPrintMatrixResults(t *testing.T) {
+	var b bytes.Buffer
+	rows := []matrixRow{{"date": "2024-01-01"}, {"date": "2024-01-02"}}
+	results := []matrixResult{
+		{taskArn: "arn:aws:ecs:us-east-1:123456789012:task/default/1", code: 0, attempts: 1},
+		{code: 1, err: errors.New("task not found"), attempts: 2},
+	}
+	code, err := printMatrixResults(&b, rows, results)
+	if code != 1 || err == nil || err.Error() != "1 of 2 rows failed" {
+		t.Errorf("printMatrixResults() = %d, %v", code, err)
+	}
+	for _, s := range []string{"ROW", "date=2024-01-01", "task/default/1", "task not found"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("printMatrixResults() = %s, want contains:%s", b.String(), s)
+		}
+	}
+}
+
+func TestRunMatrixRow(t *testing.T) {
+	var vtests = []struct {
+		resp     ecs.RunTaskOutput
+		expected int
+	}{
+		// a permanent failure is not retried
+		{ecs.RunTaskOutput{Failures: []*ecs.Failure{{Arn: aws.String("arn"), Reason: aws.String("MISSING")}}}, 1},
+		// a capacity shortage is retried
+		{ecs.RunTaskOutput{Failures: []*ecs.Failure{{Arn: aws.String("arn"), Reason: aws.String("RESOURCE:MEMORY")}}}, 3},
+	}
+	for i, vt := range vtests {
+		m := countingECS{mockedECS: mockedECS{rtresp: vt.resp}}
+		var b bytes.Buffer
+		res := runMatrixRow(&b, &m, &mockedCWL{}, &ecs.RunTaskInput{}, environments{RetryMaxAttempts: 1}, 2)
+		if res.err == nil || res.attempts != vt.expected || m.runTaskCalls != vt.expected {
+			t.Errorf("err %d:runMatrixRow() = %+v, RunTask calls:%d, want %d attempts", i, res, m.runTaskCalls, vt.expected)
+		}
+	}
+	if _, err := runMatrix(&bytes.Buffer{}, &mockedECS{}, &mockedCWL{}, environments{Count: 2}, []string{"-params", "test/params.csv", "echo"}); err == nil || !strings.Contains(err.Error(), "COUNT=2") {
+		t.Errorf("runMatrix() with COUNT=2 = %v, want error", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// getNetworkMode returns the network mode of the task definition.
+// ECS uses bridge when it is not set, except on Fargate where only awsvpc is allowed.
+func getNetworkMode(def *ecs.TaskDefinition) string {
+	if def.NetworkMode == nil {
+		return ecs.NetworkModeBridge
+	}
+	return *def.NetworkMode
+}
+
+// createNetworkConfiguration returns the network configuration for the network mode of the task definition.
+// Only awsvpc takes subnets and security groups. The settings which do not apply are returned as warnings.
+func createNetworkConfiguration(def *ecs.TaskDefinition, env environments) (*ecs.NetworkConfiguration, []string) {
+	var warnings []string
+	networkMode := getNetworkMode(def)
+	if networkMode != ecs.NetworkModeAwsvpc {
+		if len(env.Subnets)+len(env.SecurityGroups) > 0 {
+			warnings = append(warnings, fmt.Sprintf("SUBNETS and SECGROUPS are ignored: task definition %s uses %s network mode", env.TaskDefinition, networkMode))
+		}
+		if getLaunchType(env) == ecs.LaunchTypeFargate {
+			warnings = append(warnings, fmt.Sprintf("task definition %s uses %s network mode, but FARGATE requires awsvpc", env.TaskDefinition, networkMode))
+		}
+		return nil, warnings
+	}
+	if len(env.Subnets) == 0 {
+		warnings = append(warnings, fmt.Sprintf("task definition %s uses awsvpc network mode, but SUBNETS is empty", env.TaskDefinition))
+	}
+	assignPublicIP := ecs.AssignPublicIpDisabled
+	if env.AssignPublicIP && allowsPublicIP(env) {
+		assignPublicIP = ecs.AssignPublicIpEnabled
+	}
+	return &ecs.NetworkConfiguration{
+		AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+			AssignPublicIp: aws.String(assignPublicIP),
+			SecurityGroups: createStrSliceRef(env.SecurityGroups),
+			Subnets:        createStrSliceRef(env.Subnets),
+		},
+	}, warnings
+}
+
+// allowsPublicIP reports whether a task in awsvpc network mode can get a public IP.
+// Tasks on EC2 and external instances get only a private IP.
+func allowsPublicIP(env environments) bool {
+	switch getLaunchType(env) {
+	case ecs.LaunchTypeEc2, ecs.LaunchTypeExternal:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestCreateNetworkConfiguration(t *testing.T) {
+	var vtests = []struct {
+		env            environments
+		networkMode    *string
+		expectedIP     string
+		expectedSubnet int
+		warning        string
+	}{
+		{environments{LaunchType: "FARGATE", Subnets: []string{"subnet-1"}, AssignPublicIP: true}, aws.String("awsvpc"), "ENABLED", 1, ""},
+		{environments{LaunchType: "FARGATE", Subnets: []string{"subnet-1"}}, aws.String("awsvpc"), "DISABLED", 1, ""},
+		{environments{LaunchType: "EC2", Subnets: []string{"subnet-1", "subnet-2"}, AssignPublicIP: true}, aws.String("awsvpc"), "DISABLED", 2, ""},
+		{environments{LaunchType: "EC2", AssignPublicIP: true}, aws.String("awsvpc"), "DISABLED", 0, "SUBNETS is empty"},
+		{environments{LaunchType: "EC2", AssignPublicIP: true}, aws.String("bridge"), "", 0, ""},
+		{environments{LaunchType: "EC2", Subnets: []string{"subnet-1"}}, aws.String("host"), "", 0, "SUBNETS and SECGROUPS are ignored"},
+		{environments{LaunchType: "EC2", SecurityGroups: []string{"sg-1"}}, nil, "", 0, "uses bridge network mode"},
+		{environments{LaunchType: "FARGATE"}, aws.String("bridge"), "", 0, "FARGATE requires awsvpc"},
+	}
+	for i, vt := range vtests {
+		vt.env.TaskDefinition = "hoge:1"
+		res, warnings := createNetworkConfiguration(&ecs.TaskDefinition{NetworkMode: vt.networkMode}, vt.env)
+		if vt.warning == "" && len(warnings) > 0 {
+			t.Errorf("err %d:createNetworkConfiguration() warnings = %v, want:nil", i, warnings)
+		}
+		if vt.warning != "" && (len(warnings) == 0 || !strings.Contains(strings.Join(warnings, "\n"), vt.warning)) {
+			t.Errorf("err %d:createNetworkConfiguration() warnings = %v, want:%s", i, warnings, vt.warning)
+		}
+		if vt.expectedIP == "" {
+			if res != nil {
+				t.Errorf("err %d:createNetworkConfiguration() = %v, want:nil", i, res)
+			}
+			continue
+		}
+		if res == nil {
+			t.Errorf("err %d:createNetworkConfiguration() = nil", i)
+			continue
+		}
+		if ip := aws.StringValue(res.AwsvpcConfiguration.AssignPublicIp); ip != vt.expectedIP {
+			t.Errorf("err %d:createNetworkConfiguration() AssignPublicIp = %s, want:%s", i, ip, vt.expectedIP)
+		}
+		if len(res.AwsvpcConfiguration.Subnets) != vt.expectedSubnet {
+			t.Errorf("err %d:createNetworkConfiguration() Subnets = %v", i, aws.StringValueSlice(res.AwsvpcConfiguration.Subnets))
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	// maxOverridesSize is the limit of the serialized overrides of RunTask.
+	maxOverridesSize = 8192
+	// maxOverrideBreakdown is the number of the largest parts shown when the overrides are too big.
+	maxOverrideBreakdown = 5
+)
+
+func hasContainerResourceOverride(env environments) bool {
+	return env.ContainerCPU > 0 || env.ContainerMemory > 0 || env.ContainerMemoryReserve > 0
+}
+
+func applyContainerResourceOverride(override *ecs.ContainerOverride, env environments) {
+	if env.ContainerCPU > 0 {
+		override.Cpu = &env.ContainerCPU
+	}
+	if env.ContainerMemory > 0 {
+		override.Memory = &env.ContainerMemory
+	}
+	if env.ContainerMemoryReserve > 0 {
+		override.MemoryReservation = &env.ContainerMemoryReserve
+	}
+}
+
+// applyTaskOverride sets the task level overrides of input.
+func applyTaskOverride(input *ecs.RunTaskInput, env environments) {
+	if len(env.TaskCPU) > 0 {
+		getTaskOverride(input).Cpu = &env.TaskCPU
+	}
+	if len(env.TaskMemory) > 0 {
+		getTaskOverride(input).Memory = &env.TaskMemory
+	}
+	if len(env.TaskRoleARN) > 0 {
+		getTaskOverride(input).TaskRoleArn = &env.TaskRoleARN
+	}
+	if len(env.ExecutionRoleARN) > 0 {
+		getTaskOverride(input).ExecutionRoleArn = &env.ExecutionRoleARN
+	}
+	if env.EphemeralStorage > 0 {
+		getTaskOverride(input).EphemeralStorage = &ecs.EphemeralStorage{SizeInGiB: &env.EphemeralStorage}
+	}
+}
+
+func getTaskOverride(input *ecs.RunTaskInput) *ecs.TaskOverride {
+	if input.Overrides == nil {
+		input.Overrides = &ecs.TaskOverride{}
+	}
+	return input.Overrides
+}
+
+// overridesSize returns the size of the serialized overrides. The field names of the SDK differ
+// from the wire format only in case, and the unset fields are not sent, so the size is the same.
+func overridesSize(o *ecs.TaskOverride) int {
+	if o == nil {
+		return 0
+	}
+	return wireSize(o)
+}
+
+// wireSize returns the size of v in the JSON sent by the SDK.
+func wireSize(v interface{}) int {
+	m, err := pruneJSON(v)
+	if err != nil {
+		return 0
+	}
+	b, err := encodeJSON(m, "")
+	if err != nil {
+		return 0
+	}
+	// Encode appends a newline
+	return len(b) - 1
+}
+
+type overridePart struct {
+	name string
+	size int
+}
+
+// checkOverridesSize fails with the largest parts of the overrides if they exceed the limit of RunTask.
+func checkOverridesSize(o *ecs.TaskOverride) error {
+	size := overridesSize(o)
+	if size <= maxOverridesSize {
+		return nil
+	}
+	parts := overrideParts(o)
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].size > parts[j].size })
+	if len(parts) > maxOverrideBreakdown {
+		parts = parts[:maxOverrideBreakdown]
+	}
+	lines := make([]string, len(parts))
+	for i, p := range parts {
+		lines[i] = fmt.Sprintf("%s: %d bytes", p.name, p.size)
+	}
+	return fmt.Errorf("the overrides are %d bytes, which exceeds the limit of %d bytes. the largest parts:\n  - %s\nuse OVERRIDE_FALLBACK to move the command and environment into a temporary task definition revision",
+		size, maxOverridesSize, strings.Join(lines, "\n  - "))
+}
+
+func overrideParts(o *ecs.TaskOverride) []overridePart {
+	var parts []overridePart
+	for _, c := range o.ContainerOverrides {
+		name := aws.StringValue(c.Name)
+		if len(c.Command) > 0 {
+			parts = append(parts, overridePart{fmt.Sprintf("command of %s", name), wireSize(c.Command)})
+		}
+		for _, kv := range c.Environment {
+			parts = append(parts, overridePart{fmt.Sprintf("environment %s of %s", aws.StringValue(kv.Name), name), wireSize(kv)})
+		}
+		for _, f := range c.EnvironmentFiles {
+			parts = append(parts, overridePart{fmt.Sprintf("environment file %s of %s", aws.StringValue(f.Value), name), wireSize(f)})
+		}
+	}
+	return parts
+}
+
+// moveOverridesToTaskDefinition moves the command and environment of the container overrides
+// into the container definitions of the temporary revision, which has no size limit of RunTask.
+func moveOverridesToTaskDefinition(o *ecs.TaskOverride, reg *ecs.RegisterTaskDefinitionInput) {
+	if o == nil {
+		return
+	}
+	for _, override := range o.ContainerOverrides {
+		for _, c := range reg.ContainerDefinitions {
+			if aws.StringValue(c.Name) != aws.StringValue(override.Name) {
+				continue
+			}
+			if len(override.Command) > 0 {
+				c.Command = override.Command
+			}
+			if len(override.Environment) > 0 {
+				names := map[string]bool{}
+				for _, kv := range override.Environment {
+					names[aws.StringValue(kv.Name)] = true
+				}
+				var environment []*ecs.KeyValuePair
+				for _, kv := range c.Environment {
+					if !names[aws.StringValue(kv.Name)] {
+						environment = append(environment, kv)
+					}
+				}
+				c.Environment = append(environment, override.Environment...)
+			}
+			override.Command = nil
+			override.Environment = nil
+		}
+	}
+}
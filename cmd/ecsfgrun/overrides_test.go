@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestCreateRunParamOverrides(t *testing.T) {
+	m := mockedECS{
+		dtdresp: ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{
+				ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("hoge")}},
+			},
+		},
+	}
+	e := environments{
+		LaunchType:             "FARGATE",
+		Subnets:                []string{"subnet-1"},
+		TaskDefinition:         "hoge:1",
+		TaskCPU:                "1024",
+		TaskMemory:             "4096",
+		TaskRoleARN:            "arn:aws:iam::123456789012:role/task",
+		ExecutionRoleARN:       "arn:aws:iam::123456789012:role/exec",
+		ContainerMemory:        2048,
+		ContainerMemoryReserve: 1024,
+	}
+	input, err := createRunParam(&m, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := input.Overrides
+	if aws.StringValue(o.Cpu) != "1024" || aws.StringValue(o.Memory) != "4096" {
+		t.Errorf("createRunParam() Overrides = cpu:%v, memory:%v", o.Cpu, o.Memory)
+	}
+	if aws.StringValue(o.TaskRoleArn) != e.TaskRoleARN || aws.StringValue(o.ExecutionRoleArn) != e.ExecutionRoleARN {
+		t.Errorf("createRunParam() Overrides = taskRole:%v, executionRole:%v", o.TaskRoleArn, o.ExecutionRoleArn)
+	}
+	if len(o.ContainerOverrides) != 1 {
+		t.Fatalf("createRunParam() ContainerOverrides = %v", o.ContainerOverrides)
+	}
+	c := o.ContainerOverrides[0]
+	if aws.StringValue(c.Name) != "hoge" || aws.Int64Value(c.Memory) != 2048 || aws.Int64Value(c.MemoryReservation) != 1024 || c.Cpu != nil {
+		t.Errorf("createRunParam() ContainerOverride = %v", c)
+	}
+	if c.Command != nil {
+		t.Errorf("createRunParam() Command = %v, want:nil", c.Command)
+	}
+}
+
+func TestValidateContainerResources(t *testing.T) {
+	def := &ecs.TaskDefinition{Cpu: aws.String("256"), Memory: aws.String("512")}
+	var vtests = []struct {
+		env      environments
+		expected int
+	}{
+		{environments{ContainerMemory: 256, ContainerMemoryReserve: 128}, 0},
+		{environments{ContainerMemory: 256, ContainerMemoryReserve: 384}, 1},
+		{environments{ContainerMemory: 1024}, 1},
+		{environments{TaskMemory: "2048", ContainerMemory: 1024, ContainerCPU: 512}, 1},
+		{environments{TaskCPU: "1 vCPU", TaskMemory: "2 GB", ContainerMemory: 1024, ContainerCPU: 512}, 0},
+	}
+	for i, vt := range vtests {
+		var errs validationError
+		validateContainerResources(&errs, def, vt.env)
+		if len(errs) != vt.expected {
+			t.Errorf("err %d:validateContainerResources() = %v, want %d errors", i, errs, vt.expected)
+		}
+	}
+}
+
+func TestCheckOverridesSize(t *testing.T) {
+	o := &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{{
+		Name:    aws.String("app"),
+		Command: aws.StringSlice([]string{"echo", "hoge"}),
+		Environment: []*ecs.KeyValuePair{
+			{Name: aws.String("SMALL"), Value: aws.String("a")},
+			{Name: aws.String("LARGE"), Value: aws.String(strings.Repeat("a", maxOverridesSize))},
+		},
+	}}}
+	err := checkOverridesSize(o)
+	if err == nil || !strings.Contains(err.Error(), "  - environment LARGE of app: ") {
+		t.Fatalf("checkOverridesSize() = %v", err)
+	}
+	if strings.Index(err.Error(), "LARGE") > strings.Index(err.Error(), "SMALL") {
+		t.Errorf("checkOverridesSize() breakdown is not sorted by size: %s", err)
+	}
+
+	reg := &ecs.RegisterTaskDefinitionInput{ContainerDefinitions: []*ecs.ContainerDefinition{{
+		Name:        aws.String("app"),
+		Environment: []*ecs.KeyValuePair{{Name: aws.String("SMALL"), Value: aws.String("b")}, {Name: aws.String("MODE"), Value: aws.String("dev")}},
+	}}}
+	moveOverridesToTaskDefinition(o, reg)
+	if err := checkOverridesSize(o); err != nil {
+		t.Errorf("checkOverridesSize() = %v", err)
+	}
+	c := reg.ContainerDefinitions[0]
+	if strings.Join(aws.StringValueSlice(c.Command), " ") != "echo hoge" || len(c.Environment) != 3 {
+		t.Errorf("moveOverridesToTaskDefinition() = command:%v, environment:%v", c.Command, c.Environment)
+	}
+	for _, kv := range c.Environment {
+		if aws.StringValue(kv.Name) == "SMALL" && aws.StringValue(kv.Value) != "a" {
+			t.Errorf("moveOverridesToTaskDefinition() SMALL = %s, want:a", aws.StringValue(kv.Value))
+		}
+	}
+}
+
+func TestOverridesSize(t *testing.T) {
+	o := &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{{
+		Name:        aws.String("app"),
+		Command:     aws.StringSlice([]string{"sh", "-c", "echo a > b"}),
+		Environment: []*ecs.KeyValuePair{{Name: aws.String("FOO"), Value: aws.String("bar")}},
+	}}}
+	// the request body sent by the SDK
+	wire := `{"containerOverrides":[{"command":["sh","-c","echo a > b"],"environment":[{"name":"FOO","value":"bar"}],"name":"app"}]}`
+	if size := overridesSize(o); size != len(wire) {
+		t.Errorf("overridesSize() = %d, want:%d", size, len(wire))
+	}
+	if size := overridesSize(nil); size != 0 {
+		t.Errorf("overridesSize(nil) = %d, want:0", size)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	maxPlacementConstraints = 10
+	maxPlacementStrategies  = 5
+)
+
+var (
+	// see: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/cluster-query-language.html
+	placementTermRe = regexp.MustCompile(`^(attribute:[A-Za-z0-9_.:/-]+|task:group|agentConnected|agentVersion|ec2InstanceId|registeredAt|runningTasksCount)\s*(exists|==|!=|>=|<=|>|<|not_in|in|=~|!~)`)
+	placementJoinRe = regexp.MustCompile(`\s+(and|or|&&|\|\|)\s+`)
+)
+
+// semicolonsFlag is a list separated by ";" in an environment variable, and can be repeated as a flag.
+// Placement expressions may contain ",".
+type semicolonsFlag []string
+
+func (s *semicolonsFlag) Decode(v string) error {
+	*s = nil
+	for _, e := range strings.Split(v, ";") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			*s = append(*s, e)
+		}
+	}
+	return nil
+}
+
+func (s *semicolonsFlag) String() string {
+	return strings.Join(*s, ";")
+}
+
+func (s *semicolonsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// parsePlacementConstraints parses items like "memberOf:attribute:ecs.instance-type =~ g4dn.*" or "distinctInstance".
+func parsePlacementConstraints(items []string) ([]*ecs.PlacementConstraint, error) {
+	if len(items) > maxPlacementConstraints {
+		return nil, fmt.Errorf("too many placement constraints: %d, the maximum is %d", len(items), maxPlacementConstraints)
+	}
+	var res []*ecs.PlacementConstraint
+	for _, item := range items {
+		kv := strings.SplitN(item, ":", 2)
+		switch kv[0] {
+		case ecs.PlacementConstraintTypeDistinctInstance:
+			if len(kv) == 2 {
+				return nil, fmt.Errorf("invalid placement constraint %q: distinctInstance takes no expression", item)
+			}
+			res = append(res, &ecs.PlacementConstraint{Type: aws.String(kv[0])})
+		case ecs.PlacementConstraintTypeMemberOf:
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid placement constraint %q: use memberOf:<expression>", item)
+			}
+			expression := strings.TrimSpace(kv[1])
+			if err := validateExpression(expression); err != nil {
+				return nil, fmt.Errorf("invalid placement constraint %q: %s", item, err)
+			}
+			res = append(res, &ecs.PlacementConstraint{Type: aws.String(kv[0]), Expression: aws.String(expression)})
+		default:
+			return nil, fmt.Errorf("invalid placement constraint %q: the type must be memberOf or distinctInstance", item)
+		}
+	}
+	return res, nil
+}
+
+// parsePlacementStrategy parses items like "spread:attribute:ecs.availability-zone", "binpack:memory" or "random".
+func parsePlacementStrategy(items []string) ([]*ecs.PlacementStrategy, error) {
+	if len(items) > maxPlacementStrategies {
+		return nil, fmt.Errorf("too many placement strategies: %d, the maximum is %d", len(items), maxPlacementStrategies)
+	}
+	var res []*ecs.PlacementStrategy
+	for _, item := range items {
+		kv := strings.SplitN(item, ":", 2)
+		s := &ecs.PlacementStrategy{Type: aws.String(kv[0])}
+		if len(kv) == 2 {
+			s.Field = aws.String(kv[1])
+		}
+		switch kv[0] {
+		case ecs.PlacementStrategyTypeRandom:
+			if s.Field != nil {
+				return nil, fmt.Errorf("invalid placement strategy %q: random takes no field", item)
+			}
+		case ecs.PlacementStrategyTypeSpread:
+			if s.Field == nil {
+				return nil, fmt.Errorf("invalid placement strategy %q: use spread:instanceId or spread:attribute:<name>", item)
+			}
+		case ecs.PlacementStrategyTypeBinpack:
+			switch aws.StringValue(s.Field) {
+			case "cpu", "memory":
+			default:
+				return nil, fmt.Errorf("invalid placement strategy %q: use binpack:cpu or binpack:memory", item)
+			}
+		default:
+			return nil, fmt.Errorf("invalid placement strategy %q: the type must be random, spread or binpack", item)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// validateExpression checks the basic syntax of a cluster query language expression.
+func validateExpression(expression string) error {
+	if len(expression) == 0 {
+		return fmt.Errorf("the expression is empty")
+	}
+	depth := 0
+	for _, c := range expression {
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		}
+		if depth < 0 {
+			return fmt.Errorf("unbalanced brackets")
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unbalanced brackets")
+	}
+	for _, term := range placementJoinRe.Split(expression, -1) {
+		term = strings.TrimLeft(term, "(! ")
+		if !placementTermRe.MatchString(term) {
+			return fmt.Errorf("%q is not <attribute> <operator> [value]", term)
+		}
+	}
+	return nil
+}
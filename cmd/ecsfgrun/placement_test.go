@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestParsePlacementConstraints(t *testing.T) {
+	var vtests = []struct {
+		input []string
+		err   bool
+	}{
+		{[]string{"distinctInstance"}, false},
+		{[]string{"memberOf:attribute:ecs.instance-type =~ g4dn.*"}, false},
+		{[]string{"memberOf:attribute:ecs.availability-zone in [us-east-1a, us-east-1b]"}, false},
+		{[]string{"memberOf:attribute:gpu exists and (attribute:ecs.os-type == linux or task:group == hoge)"}, false},
+		{[]string{"memberOf:!(attribute:ecs.instance-type == t2.micro)"}, false},
+		{[]string{"memberOf:attribute:ecs.instance-type in [t2.small"}, true},
+		{[]string{"memberOf:instance-type == t2.micro"}, true},
+		{[]string{"memberOf:"}, true},
+		{[]string{"memberOf"}, true},
+		{[]string{"distinctInstance:hoge"}, true},
+		{[]string{"hoge:attribute:gpu exists"}, true},
+	}
+	for _, vt := range vtests {
+		res, err := parsePlacementConstraints(vt.input)
+		if (err != nil) != vt.err {
+			t.Errorf("parsePlacementConstraints(%q) = err:%v, want err:%v", vt.input, err, vt.err)
+			continue
+		}
+		if err == nil && len(res) != len(vt.input) {
+			t.Errorf("parsePlacementConstraints(%q) = %v", vt.input, res)
+		}
+	}
+}
+
+func TestParsePlacementStrategy(t *testing.T) {
+	var vtests = []struct {
+		input    []string
+		expected []string
+		err      bool
+	}{
+		{[]string{"spread:attribute:ecs.availability-zone", "binpack:memory"}, []string{"attribute:ecs.availability-zone", "memory"}, false},
+		{[]string{"random"}, []string{""}, false},
+		{[]string{"random:cpu"}, nil, true},
+		{[]string{"spread"}, nil, true},
+		{[]string{"binpack:disk"}, nil, true},
+		{[]string{"hoge"}, nil, true},
+	}
+	for _, vt := range vtests {
+		res, err := parsePlacementStrategy(vt.input)
+		if (err != nil) != vt.err {
+			t.Errorf("parsePlacementStrategy(%q) = err:%v, want err:%v", vt.input, err, vt.err)
+			continue
+		}
+		for i := range vt.expected {
+			if aws.StringValue(res[i].Field) != vt.expected[i] {
+				t.Errorf("parsePlacementStrategy(%q)[%d] = %v, want field:%s", vt.input, i, res[i], vt.expected[i])
+			}
+		}
+	}
+}
+
+func TestSemicolonsFlag(t *testing.T) {
+	var s semicolonsFlag
+	if err := s.Decode("memberOf:attribute:a in [x, y]; distinctInstance;"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 2 || s[0] != "memberOf:attribute:a in [x, y]" || s[1] != "distinctInstance" {
+		t.Errorf("Decode() = %q", s)
+	}
+	if err := s.Set("memberOf:attribute:b exists"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 3 {
+		t.Errorf("Set() = %q", s)
+	}
+}
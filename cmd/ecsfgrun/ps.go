@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+const (
+	startedByPrefix = "ecsfgrun/"
+	groupPrefix     = "ecsfgrun:"
+
+	maxStartedByLen     = 128
+	maxDescribeTasksLen = 100
+)
+
+var startedByRe = regexp.MustCompile(`[^A-Za-z0-9_/-]`)
+
+// getStartedBy returns the startedBy of the task. startedBy accepts only letters, numbers, "-", "/" and "_".
+func getStartedBy(env environments) string {
+	startedBy := env.StartedBy
+	if len(startedBy) == 0 {
+		startedBy = startedByPrefix + getUserName()
+	}
+	startedBy = startedByRe.ReplaceAllString(startedBy, "_")
+	if len(startedBy) > maxStartedByLen {
+		startedBy = startedBy[:maxStartedByLen]
+	}
+	return startedBy
+}
+
+// getGroup returns the task group. It is "ecsfgrun:<job-name>" unless GROUP is set.
+func getGroup(env environments) string {
+	if len(env.Group) > 0 {
+		return env.Group
+	}
+	if len(env.JobName) > 0 {
+		return groupPrefix + env.JobName
+	}
+	return ""
+}
+
+// runPs lists the running tasks started by ecsfgrun.
+func runPs(w io.Writer, client ecsiface.ECSAPI, env environments, args []string) (int, error) {
+	fs := flag.NewFlagSet("ps", flag.ContinueOnError)
+	all := fs.Bool("a", false, "show the tasks started by all users")
+	if err := fs.Parse(args); err != nil {
+		return 1, err
+	}
+	if err := checkNoArgs(fs); err != nil {
+		return 1, err
+	}
+	startedBy := getStartedBy(env)
+	if *all {
+		startedBy = ""
+	}
+	tasks, err := listTasks(client, env.Cluster, startedBy)
+	if err != nil {
+		return 1, err
+	}
+	printTasks(w, tasks, time.Now())
+	return 0, nil
+}
+
+// checkNoArgs rejects the arguments left after the flags of a subcommand. They were the command
+// line of the container before the subcommand existed, e.g. "ecsfgrun ps aux".
+func checkNoArgs(fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s takes no arguments: %s. to run the command in the container, use ecsfgrun -exec %s %s",
+		fs.Name(), strings.Join(fs.Args(), " "), fs.Name(), strings.Join(fs.Args(), " "))
+}
+
+// listTasks returns the running tasks with startedBy. If startedBy is empty, the tasks started
+// by ecsfgrun for all users are listed.
+func listTasks(client ecsiface.ECSAPI, cluster, startedBy string) ([]*ecs.Task, error) {
+	input := &ecs.ListTasksInput{Cluster: &cluster, DesiredStatus: aws.String(ecs.DesiredStatusRunning)}
+	if len(startedBy) > 0 {
+		input.StartedBy = &startedBy
+	}
+	var arns []*string
+	for {
+		res, err := client.ListTasks(input)
+		if err != nil {
+			return nil, err
+		}
+		arns = append(arns, res.TaskArns...)
+		if res.NextToken == nil {
+			break
+		}
+		input.NextToken = res.NextToken
+	}
+	var tasks []*ecs.Task
+	for len(arns) > 0 {
+		n := len(arns)
+		if n > maxDescribeTasksLen {
+			n = maxDescribeTasksLen
+		}
+		res, err := client.DescribeTasks(&ecs.DescribeTasksInput{Cluster: &cluster, Tasks: arns[:n]})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range res.Tasks {
+			// ListTasks has filtered the tasks unless startedBy is empty
+			if len(startedBy) > 0 || strings.HasPrefix(aws.StringValue(task.StartedBy), startedByPrefix) {
+				tasks = append(tasks, task)
+			}
+		}
+		arns = arns[n:]
+	}
+	return tasks, nil
+}
+
+func printTasks(w io.Writer, tasks []*ecs.Task, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK ID\tSTARTED BY\tGROUP\tSTATUS\tAGE\tCOMMAND")
+	for _, task := range tasks {
+		age := "-"
+		if task.CreatedAt != nil {
+			age = now.Sub(*task.CreatedAt).Truncate(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			getTaskID(task.TaskArn),
+			aws.StringValue(task.StartedBy),
+			aws.StringValue(task.Group),
+			aws.StringValue(task.LastStatus),
+			age,
+			getTaskCommand(task),
+		)
+	}
+	tw.Flush() // nolint errcheck
+}
+
+// getTaskCommand returns the overridden command of the task.
+func getTaskCommand(task *ecs.Task) string {
+	if task.Overrides == nil {
+		return ""
+	}
+	for _, o := range task.Overrides.ContainerOverrides {
+		if len(o.Command) > 0 {
+			return strings.Join(aws.StringValueSlice(o.Command), " ")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+type listTasksECS struct {
+	mockedECS
+	pages      []ecs.ListTasksOutput
+	startedBy  *string
+	describeIn [][]*string
+}
+
+func (m *listTasksECS) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	m.startedBy = input.StartedBy
+	i := 0
+	if input.NextToken != nil {
+		i = int(aws.StringValue(input.NextToken)[0] - '0')
+	}
+	return &m.pages[i], m.err
+}
+
+func (m *listTasksECS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	m.describeIn = append(m.describeIn, input.Tasks)
+	return &m.dtresp, m.err
+}
+
+func TestGetStartedBy(t *testing.T) {
+	var vtests = []struct {
+		env      environments
+		expected string
+	}{
+		{environments{StartedBy: "ci/job-1"}, "ci/job-1"},
+		{environments{StartedBy: "first.last@example.com"}, "first_last_example_com"},
+		{environments{StartedBy: strings.Repeat("a", 200)}, strings.Repeat("a", 128)},
+	}
+	for _, vt := range vtests {
+		if res := getStartedBy(vt.env); res != vt.expected {
+			t.Errorf("getStartedBy(%q) = %q, want:%q", vt.env.StartedBy, res, vt.expected)
+		}
+	}
+	if res := getStartedBy(environments{}); !strings.HasPrefix(res, startedByPrefix) {
+		t.Errorf("getStartedBy() = %q, want prefix:%q", res, startedByPrefix)
+	}
+}
+
+func TestGetGroup(t *testing.T) {
+	var vtests = []struct {
+		env      environments
+		expected string
+	}{
+		{environments{}, ""},
+		{environments{JobName: "backfill"}, "ecsfgrun:backfill"},
+		{environments{JobName: "backfill", Group: "family:hoge"}, "family:hoge"},
+	}
+	for _, vt := range vtests {
+		if res := getGroup(vt.env); res != vt.expected {
+			t.Errorf("getGroup(%v) = %q, want:%q", vt.env, res, vt.expected)
+		}
+	}
+}
+
+func TestRunPs(t *testing.T) {
+	now := time.Now()
+	arns := make([]*string, 150)
+	for i := range arns {
+		arns[i] = aws.String("arn:aws:ecs:us-east-1:123456789012:task/hoge/0000")
+	}
+	m := listTasksECS{
+		pages: []ecs.ListTasksOutput{
+			{TaskArns: arns[:100], NextToken: aws.String("1")},
+			{TaskArns: arns[100:]},
+		},
+		mockedECS: mockedECS{
+			dtresp: ecs.DescribeTasksOutput{
+				Tasks: []*ecs.Task{
+					{
+						TaskArn:    aws.String("arn:aws:ecs:us-east-1:123456789012:task/hoge/305b887f"),
+						StartedBy:  aws.String("ecsfgrun/hoge"),
+						Group:      aws.String("ecsfgrun:backfill"),
+						LastStatus: aws.String("RUNNING"),
+						CreatedAt:  aws.Time(now.Add(-90 * time.Second)),
+						Overrides: &ecs.TaskOverride{
+							ContainerOverrides: []*ecs.ContainerOverride{
+								{Command: []*string{aws.String("echo"), aws.String("hoge")}},
+							},
+						},
+					},
+					{
+						TaskArn:   aws.String("arn:aws:ecs:us-east-1:123456789012:task/hoge/0123abcd"),
+						StartedBy: aws.String("ecs-svc/1234567890"),
+					},
+				},
+			},
+		},
+	}
+	var b bytes.Buffer
+	code, err := runPs(&b, &m, environments{StartedBy: "ecsfgrun/hoge"}, []string{"-a"})
+	if code != 0 || err != nil {
+		t.Fatalf("runPs(-a) = %d, err:%v", code, err)
+	}
+	if m.startedBy != nil {
+		t.Errorf("runPs(-a) ListTasks startedBy = %v, want:nil", *m.startedBy)
+	}
+	if len(m.describeIn) != 2 || len(m.describeIn[0]) != 100 || len(m.describeIn[1]) != 50 {
+		t.Errorf("runPs(-a) DescribeTasks batches = %d", len(m.describeIn))
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	// header and the task started by ecsfgrun in each of the two batches
+	if len(lines) != 3 {
+		t.Fatalf("runPs(-a) = %s", b.String())
+	}
+	for _, c := range []string{"305b887f", "ecsfgrun:backfill", "RUNNING", "1m30s", "echo hoge"} {
+		if !strings.Contains(lines[1], c) {
+			t.Errorf("runPs(-a) = %s, want contains:%s", lines[1], c)
+		}
+	}
+
+	// ListTasks filters the tasks by a custom STARTED_BY
+	b.Reset()
+	m.describeIn = nil
+	if _, err := runPs(&b, &m, environments{StartedBy: "ci/job-1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(m.startedBy) != "ci/job-1" {
+		t.Errorf("runPs() ListTasks startedBy = %v, want:ci/job-1", m.startedBy)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 5 {
+		t.Errorf("runPs() = %s", b.String())
+	}
+
+	if _, err := runPs(&b, &m, environments{}, []string{"aux"}); err == nil || !strings.Contains(err.Error(), "-exec") {
+		t.Errorf("runPs(aux) err:%v, want error suggesting -exec", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+const maxRetryBackoff = 5 * time.Minute
+
+// taskStoppedError is returned when the task is stopped for an infrastructure reason.
+type taskStoppedError struct {
+	task *ecs.Task
+}
+
+func (e *taskStoppedError) Error() string {
+	return fmt.Sprintf("task %s stopped: %s (%s)", getTaskID(e.task.TaskArn), aws.StringValue(e.task.StopCode), aws.StringValue(e.task.StoppedReason))
+}
+
+func runWithRetry(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, input *ecs.RunTaskInput, env environments) (int, error) {
+	var code int
+	var err error
+	retryWithBackoff(env.RetryBackoff, env.RetryMaxAttempts, func(attempt int) (bool, string) {
+		if attempt > 1 && env.RetryOnDemand {
+			fallbackOnDemand(input)
+		}
+		code, err = runTask(w, ecsSv, logsSv, input, env, attempt)
+		if err == nil || !isRetryable(err) {
+			return false, ""
+		}
+		return true, err.Error()
+	})
+	return code, err
+}
+
+// retryWithBackoff calls f until it reports no retry or maxAttempts are used up. f returns whether
+// to retry and the reason. The wait before a retry starts at backoff and doubles up to maxRetryBackoff.
+func retryWithBackoff(backoff time.Duration, maxAttempts int, f func(attempt int) (retry bool, reason string)) {
+	for attempt := 1; ; attempt++ {
+		retry, reason := f(attempt)
+		if !retry || attempt >= maxAttempts {
+			return
+		}
+		log.Printf("attempt %d/%d failed: %s. retry after %s", attempt, maxAttempts, reason, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// isRetryable reports whether err is caused by a Spot interruption or a capacity shortage.
+func isRetryable(err error) bool {
+	switch e := err.(type) {
+	case *taskStoppedError:
+		return true
+	case *runTaskFailure:
+		reason := aws.StringValue(e.failure.Reason)
+		return strings.HasPrefix(reason, "RESOURCE:") ||
+			strings.HasPrefix(reason, "AGENT") ||
+			strings.Contains(strings.ToLower(reason), "capacity")
+	}
+	return false
+}
+
+// isInfraStop reports whether the task was stopped by the infrastructure rather than by its own exit.
+func isInfraStop(task *ecs.Task) bool {
+	if task == nil {
+		return false
+	}
+	switch aws.StringValue(task.StopCode) {
+	case ecs.TaskStopCodeSpotInterruption, ecs.TaskStopCodeTerminationNotice:
+		return true
+	case ecs.TaskStopCodeTaskFailedToStart:
+		return strings.Contains(strings.ToLower(aws.StringValue(task.StoppedReason)), "capacity")
+	}
+	return false
+}
+
+// fallbackOnDemand removes FARGATE_SPOT from the capacity provider strategy of input.
+func fallbackOnDemand(input *ecs.RunTaskInput) {
+	if len(input.CapacityProviderStrategy) == 0 {
+		return
+	}
+	strategy := make([]*ecs.CapacityProviderStrategyItem, 0, len(input.CapacityProviderStrategy))
+	for _, item := range input.CapacityProviderStrategy {
+		if aws.StringValue(item.CapacityProvider) == capacityProviderFargateSpot {
+			continue
+		}
+		strategy = append(strategy, item)
+	}
+	if len(strategy) == 0 {
+		strategy = append(strategy, &ecs.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(capacityProviderFargate),
+			Weight:           aws.Int64(1),
+		})
+	}
+	if len(strategy) != len(input.CapacityProviderStrategy) {
+		log.Printf("fall back to on-demand capacity")
+	}
+	input.CapacityProviderStrategy = strategy
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+type countingECS struct {
+	mockedECS
+	runTaskCalls int
+}
+
+func (m *countingECS) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	m.runTaskCalls++
+	return m.mockedECS.RunTask(input)
+}
+
+func TestRunWithRetry(t *testing.T) {
+	var vtests = []struct {
+		reason   string
+		attempts int
+		expected int
+	}{
+		{"RESOURCE:MEMORY", 3, 3},
+		{"Capacity is unavailable at this time. Please try again later or in a different availability zone", 2, 2},
+		{"MISSING", 3, 1},
+		{"RESOURCE:CPU", 1, 1},
+	}
+	for i, vt := range vtests {
+		m := countingECS{mockedECS: mockedECS{
+			rtresp: ecs.RunTaskOutput{
+				Failures: []*ecs.Failure{{Arn: aws.String("arn"), Reason: aws.String(vt.reason)}},
+			},
+		}}
+		e := environments{RetryMaxAttempts: vt.attempts}
+		var b bytes.Buffer
+		code, err := runWithRetry(&b, &m, &mockedCWL{}, &ecs.RunTaskInput{}, e)
+		if err == nil || code != 1 {
+			t.Errorf("err %d:runWithRetry() = %d, err:%v", i, code, err)
+		}
+		if m.runTaskCalls != vt.expected {
+			t.Errorf("err %d:runWithRetry() RunTask calls = %d, want:%d", i, m.runTaskCalls, vt.expected)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	var vtests = []struct {
+		err      error
+		expected bool
+	}{
+		{&runTaskFailure{&ecs.Failure{Reason: aws.String("RESOURCE:MEMORY")}}, true},
+		{&runTaskFailure{&ecs.Failure{Reason: aws.String("MISSING")}}, false},
+		{&taskStoppedError{&ecs.Task{StopCode: aws.String(ecs.TaskStopCodeSpotInterruption)}}, true},
+		{errors.New("test error"), false},
+	}
+	for i, vt := range vtests {
+		if res := isRetryable(vt.err); res != vt.expected {
+			t.Errorf("err %d:isRetryable(%v) = %v, want:%v", i, vt.err, res, vt.expected)
+		}
+	}
+}
+
+func TestIsInfraStop(t *testing.T) {
+	var vtests = []struct {
+		task     *ecs.Task
+		expected bool
+	}{
+		{&ecs.Task{StopCode: aws.String(ecs.TaskStopCodeSpotInterruption)}, true},
+		{&ecs.Task{StopCode: aws.String(ecs.TaskStopCodeTerminationNotice)}, true},
+		{&ecs.Task{StopCode: aws.String(ecs.TaskStopCodeTaskFailedToStart), StoppedReason: aws.String("Capacity is unavailable at this time")}, true},
+		{&ecs.Task{StopCode: aws.String(ecs.TaskStopCodeTaskFailedToStart), StoppedReason: aws.String("CannotPullContainerError")}, false},
+		{&ecs.Task{StopCode: aws.String(ecs.TaskStopCodeEssentialContainerExited)}, false},
+		{nil, false},
+	}
+	for i, vt := range vtests {
+		if res := isInfraStop(vt.task); res != vt.expected {
+			t.Errorf("err %d:isInfraStop() = %v, want:%v", i, res, vt.expected)
+		}
+	}
+}
+
+func TestFallbackOnDemand(t *testing.T) {
+	var vtests = []struct {
+		strategy []string
+		expected []string
+	}{
+		{[]string{"FARGATE_SPOT:3", "FARGATE:1"}, []string{"FARGATE"}},
+		{[]string{"FARGATE_SPOT"}, []string{"FARGATE"}},
+		{[]string{"my-provider"}, []string{"my-provider"}},
+	}
+	for i, vt := range vtests {
+		strategy, err := parseCapacityProviders(vt.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := ecs.RunTaskInput{CapacityProviderStrategy: strategy}
+		fallbackOnDemand(&input)
+		if len(input.CapacityProviderStrategy) != len(vt.expected) {
+			t.Errorf("err %d:fallbackOnDemand() = %v, want:%v", i, input.CapacityProviderStrategy, vt.expected)
+			continue
+		}
+		for j, item := range input.CapacityProviderStrategy {
+			if aws.StringValue(item.CapacityProvider) != vt.expected[j] {
+				t.Errorf("err %d:fallbackOnDemand() = %v, want:%v", i, input.CapacityProviderStrategy, vt.expected)
+			}
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	var vtests = []struct {
+		failures    int
+		maxAttempts int
+		expected    int
+	}{
+		{0, 3, 1},
+		{1, 3, 2},
+		{5, 3, 3},
+		{5, 0, 1},
+	}
+	for i, vt := range vtests {
+		calls := 0
+		retryWithBackoff(0, vt.maxAttempts, func(attempt int) (bool, string) {
+			calls++
+			if attempt != calls {
+				t.Errorf("err %d:retryWithBackoff() attempt = %d, want:%d", i, attempt, calls)
+			}
+			return calls <= vt.failures, "test error"
+		})
+		if calls != vt.expected {
+			t.Errorf("err %d:retryWithBackoff() calls = %d, want:%d", i, calls, vt.expected)
+		}
+	}
+}
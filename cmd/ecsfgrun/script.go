@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	// scriptEnvName is the environment variable of the container which holds the encoded script.
+	scriptEnvName = "ECSFGRUN_SCRIPT"
+	// scriptArg0 is $0 of the script.
+	scriptArg0 = "ecsfgrun-script"
+	// scriptDecoder decodes the script and runs it in the interpreter. The container needs base64 and gzip.
+	scriptDecoder = `eval "$(printf %s "$` + scriptEnvName + `" | base64 -d | gzip -dc)"`
+)
+
+// createScriptCmd returns the command which runs the local script file in the container,
+// and the environment variable which carries the script compressed and base64 encoded.
+// interpreter is a shell with the option to run a command string, like "sh -c" or "bash -c".
+// args are passed to the script as $1, $2, ...
+func createScriptCmd(path, interpreter string, args []string) ([]*string, *ecs.KeyValuePair, error) {
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("the script interpreter is empty: use \"sh -c\" or \"bash -c\"")
+	}
+	script, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded, err := encodeScript(script)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode %s err:%s", path, err)
+	}
+	command := append(fields, scriptDecoder, scriptArg0)
+	command = append(command, args...)
+	return aws.StringSlice(command), &ecs.KeyValuePair{Name: aws.String(scriptEnvName), Value: aws.String(encoded)}, nil
+}
+
+func encodeScript(script []byte) (string, error) {
+	var b bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := zw.Write(script); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
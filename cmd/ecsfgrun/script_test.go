@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const testScriptFile = "./test/migrate.sh"
+
+func TestCreateScriptCmd(t *testing.T) {
+	command, kv, err := createScriptCmd(testScriptFile, "bash  -c", []string{"v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"bash", "-c", scriptDecoder, scriptArg0, "v2"}
+	res := aws.StringValueSlice(command)
+	if len(res) != len(expected) {
+		t.Fatalf("createScriptCmd() = %q, want:%q", res, expected)
+	}
+	for i := range expected {
+		if res[i] != expected[i] {
+			t.Errorf("err %d:createScriptCmd() = %q, want:%q", i, res[i], expected[i])
+		}
+	}
+	if aws.StringValue(kv.Name) != scriptEnvName {
+		t.Errorf("createScriptCmd() env = %s, want:%s", aws.StringValue(kv.Name), scriptEnvName)
+	}
+	b, err := base64.StdEncoding.DecodeString(aws.StringValue(kv.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, _ := ioutil.ReadFile(testScriptFile) // nolint errcheck
+	if !bytes.Equal(script, original) {
+		t.Errorf("createScriptCmd() script = %q, want:%q", script, original)
+	}
+
+	if _, _, err := createScriptCmd(testScriptFile, " ", nil); err == nil {
+		t.Error("createScriptCmd() = nil, want error")
+	}
+	if _, _, err := createScriptCmd("./test/none.sh", "sh -c", nil); err == nil {
+		t.Error("createScriptCmd() = nil, want error")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	secretPrefixSSM            = "ssm:"
+	secretPrefixSecretsManager = "secretsmanager:"
+)
+
+// isSecretRef reports whether v refers to a SSM parameter or a Secrets Manager secret.
+func isSecretRef(v string) bool {
+	return strings.HasPrefix(v, secretPrefixSSM) || strings.HasPrefix(v, secretPrefixSecretsManager)
+}
+
+// parseSecretRef converts "ssm:/path" or "secretsmanager:<arn>" into a secret which ECS resolves at launch.
+func parseSecretRef(name, v string) (*ecs.Secret, error) {
+	var valueFrom string
+	switch {
+	case strings.HasPrefix(v, secretPrefixSSM):
+		valueFrom = strings.TrimPrefix(v, secretPrefixSSM)
+	case strings.HasPrefix(v, secretPrefixSecretsManager):
+		valueFrom = strings.TrimPrefix(v, secretPrefixSecretsManager)
+		if !strings.HasPrefix(valueFrom, "arn:") {
+			return nil, fmt.Errorf("invalid secret %s: use secretsmanager:arn:aws:secretsmanager:<region>:<account>:secret:<name>", name)
+		}
+	default:
+		return nil, fmt.Errorf("invalid secret %s: the value must start with ssm: or secretsmanager:", name)
+	}
+	if len(valueFrom) == 0 {
+		return nil, fmt.Errorf("invalid secret %s: the reference is empty", name)
+	}
+	return &ecs.Secret{Name: aws.String(name), ValueFrom: aws.String(valueFrom)}, nil
+}
+
+// getSecrets returns the secrets of the container. They are the override environment variables
+// whose values are secret references, then SECRETS and -secret, which take precedence.
+// The override environment variables are used only if withOverrideVars is set, like the
+// plain values, which are sent only with the override environment (see sendsOverrideEnv).
+func getSecrets(env environments, withOverrideVars bool) ([]*ecs.Secret, error) {
+	refs := map[string]string{}
+	if withOverrideVars {
+		vars, err := getOverrideVars(env)
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range vars {
+			if isSecretRef(v) {
+				refs[name] = v
+			}
+		}
+	}
+	for _, s := range env.Secrets {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, fmt.Errorf("invalid secret %q: use NAME=ssm:/path or NAME=secretsmanager:<arn>", s)
+		}
+		refs[kv[0]] = kv[1]
+	}
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var res []*ecs.Secret
+	for _, name := range names {
+		secret, err := parseSecretRef(name, refs[name])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, secret)
+	}
+	return res, nil
+}
+
+// addSecrets adds the secrets to the container definition. A secret or an environment variable
+// of the same name in the task definition is replaced.
+func addSecrets(input *ecs.RegisterTaskDefinitionInput, secrets []*ecs.Secret, container string) error {
+	if len(secrets) == 0 {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, s := range secrets {
+		names[aws.StringValue(s.Name)] = true
+	}
+	for _, c := range input.ContainerDefinitions {
+		if aws.StringValue(c.Name) != container {
+			continue
+		}
+		var environment []*ecs.KeyValuePair
+		for _, kv := range c.Environment {
+			if !names[aws.StringValue(kv.Name)] {
+				environment = append(environment, kv)
+			}
+		}
+		res := make([]*ecs.Secret, 0, len(c.Secrets)+len(secrets))
+		for _, s := range c.Secrets {
+			if !names[aws.StringValue(s.Name)] {
+				res = append(res, s)
+			}
+		}
+		c.Environment = environment
+		c.Secrets = append(res, secrets...)
+		return nil
+	}
+	return fmt.Errorf("container %q is not found in the task definition", container)
+}
+
+func hasSecrets(def *ecs.TaskDefinition) bool {
+	for _, c := range def.ContainerDefinitions {
+		if len(c.Secrets) > 0 {
+			return true
+		}
+	}
+	return false
+}
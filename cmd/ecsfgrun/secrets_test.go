@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestGetSecrets(t *testing.T) {
+	os.Setenv("SECRETTEST_DB_PASSWORD", "ssm:/prod/db/password")
+	os.Setenv("SECRETTEST_API_KEY", "secretsmanager:arn:aws:secretsmanager:us-east-1:123456789012:secret:api-key")
+	os.Setenv("SECRETTEST_PLAIN", "hoge")
+	defer os.Unsetenv("SECRETTEST_DB_PASSWORD")
+	defer os.Unsetenv("SECRETTEST_API_KEY")
+	defer os.Unsetenv("SECRETTEST_PLAIN")
+
+	res, err := getSecrets(environments{OverrideEnvPrefix: "SECRETTEST_", Secrets: []string{"DB_PASSWORD=ssm:/dev/db/password"}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]string{
+		{"API_KEY", "arn:aws:secretsmanager:us-east-1:123456789012:secret:api-key"},
+		{"DB_PASSWORD", "/dev/db/password"},
+	}
+	if len(res) != len(expected) {
+		t.Fatalf("getSecrets() = %v", res)
+	}
+	for i, s := range res {
+		if aws.StringValue(s.Name) != expected[i][0] || aws.StringValue(s.ValueFrom) != expected[i][1] {
+			t.Errorf("err %d:getSecrets() = %s=%s, want:%s=%s", i, aws.StringValue(s.Name), aws.StringValue(s.ValueFrom), expected[i][0], expected[i][1])
+		}
+	}
+	// the override environment is not sent, so neither are its secret references
+	res, err = getSecrets(environments{OverrideEnvPrefix: "SECRETTEST_", Secrets: []string{"DB_PASSWORD=ssm:/dev/db/password"}}, false)
+	if err != nil || len(res) != 1 || aws.StringValue(res[0].Name) != "DB_PASSWORD" {
+		t.Errorf("getSecrets() = %v, %v, want only DB_PASSWORD", res, err)
+	}
+	envs, err := getOverrideEnvs(environments{OverrideEnvPrefix: "SECRETTEST_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 1 || aws.StringValue(envs[0].Name) != "PLAIN" {
+		t.Errorf("getOverrideEnvs() = %v, want only PLAIN", envs)
+	}
+
+	for i, secrets := range [][]string{{"hoge"}, {"=ssm:/a"}, {"A=plain"}, {"A=ssm:"}, {"A=secretsmanager:api-key"}} {
+		if _, err := getSecrets(environments{OverrideEnvPrefix: "SECRETTEST_", Secrets: secrets}, true); err == nil {
+			t.Errorf("err %d:getSecrets(%v) = nil, want error", i, secrets)
+		}
+	}
+}
+
+func TestCreateRegisterParamSecrets(t *testing.T) {
+	m := taskDefECS{mockedECS: mockedECS{
+		dtdresp: ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{
+				Family: aws.String("hoge"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{
+						Name:        aws.String("app"),
+						Environment: []*ecs.KeyValuePair{{Name: aws.String("TOKEN"), Value: aws.String("dummy")}, {Name: aws.String("MODE"), Value: aws.String("dev")}},
+						Secrets:     []*ecs.Secret{{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("/dev/db/password")}},
+					},
+				},
+			},
+		},
+	}}
+	input, err := createRegisterParam(&m, environments{TaskDefinition: "hoge:1", Secrets: []string{"TOKEN=ssm:/prod/token"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input == nil {
+		t.Fatal("createRegisterParam() = nil, want a temporary revision")
+	}
+	c := input.ContainerDefinitions[0]
+	if len(c.Environment) != 1 || aws.StringValue(c.Environment[0].Name) != "MODE" {
+		t.Errorf("createRegisterParam() Environment = %v", c.Environment)
+	}
+	if len(c.Secrets) != 2 || aws.StringValue(c.Secrets[1].Name) != "TOKEN" || aws.StringValue(c.Secrets[1].ValueFrom) != "/prod/token" {
+		t.Errorf("createRegisterParam() Secrets = %v", c.Secrets)
+	}
+	if len(m.dtdresp.TaskDefinition.ContainerDefinitions[0].Secrets) != 1 {
+		t.Errorf("createRegisterParam() modified the described task definition")
+	}
+	os.Setenv("SECRETTEST_TOKEN", "ssm:/prod/token")
+	defer os.Unsetenv("SECRETTEST_TOKEN")
+	e := environments{TaskDefinition: "hoge:1", OverrideEnvPrefix: "SECRETTEST_"}
+	if input, err := createRegisterParam(&m, e, false); err != nil || input != nil {
+		t.Errorf("createRegisterParam() = %v, %v, want no revision without the override environment", input, err)
+	}
+	if input, err := createRegisterParam(&m, e, true); err != nil || input == nil {
+		t.Errorf("createRegisterParam() = %v, %v, want a temporary revision", input, err)
+	}
+}
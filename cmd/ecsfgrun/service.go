@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+// applyLikeService copies the settings of the service given by LIKE_SERVICE to env, so that a one-off task
+// runs in the same network and on the same capacity as the service. The cluster, the launch type or
+// capacity provider strategy and the public IP always follow the service. The subnets, security groups,
+// platform version and task definition are taken only when they are not set.
+func applyLikeService(client ecsiface.ECSAPI, env environments) (environments, error) {
+	cluster, name := splitServiceName(env.LikeService, env.Cluster)
+	input := &ecs.DescribeServicesInput{Services: []*string{&name}}
+	if len(cluster) > 0 {
+		input.Cluster = &cluster
+	}
+	res, err := client.DescribeServices(input)
+	if err != nil {
+		return env, err
+	}
+	if len(res.Services) == 0 {
+		reason := "not found"
+		if len(res.Failures) > 0 {
+			reason = strings.ToLower(aws.StringValue(res.Failures[0].Reason))
+		}
+		return env, fmt.Errorf("service %s is %s in cluster %s", env.LikeService, reason, aws.StringValue(input.Cluster))
+	}
+	service := res.Services[0]
+	if aws.StringValue(service.Status) == "INACTIVE" {
+		return env, fmt.Errorf("service %s is inactive", env.LikeService)
+	}
+	env.Cluster = aws.StringValue(service.ClusterArn)
+	if len(service.CapacityProviderStrategy) > 0 {
+		env.CapacityProviders = formatCapacityProviders(service.CapacityProviderStrategy)
+	} else if service.LaunchType != nil {
+		env.LaunchType = *service.LaunchType
+		env.CapacityProviders = nil
+	}
+	if service.NetworkConfiguration != nil && service.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := service.NetworkConfiguration.AwsvpcConfiguration
+		if len(env.Subnets) == 0 {
+			env.Subnets = aws.StringValueSlice(vpc.Subnets)
+		}
+		if len(env.SecurityGroups) == 0 {
+			env.SecurityGroups = aws.StringValueSlice(vpc.SecurityGroups)
+		}
+		env.AssignPublicIP = aws.StringValue(vpc.AssignPublicIp) == ecs.AssignPublicIpEnabled
+	}
+	if len(env.PlatformVersion) == 0 && aws.StringValue(service.PlatformVersion) != "LATEST" {
+		env.PlatformVersion = aws.StringValue(service.PlatformVersion)
+	}
+	if len(env.TaskDefinition) == 0 && len(env.TaskDefinitionFile) == 0 {
+		env.TaskDefinition = aws.StringValue(service.TaskDefinition)
+	}
+	log.Printf("run like service %s in cluster %s", aws.StringValue(service.ServiceName), env.Cluster)
+	return env, nil
+}
+
+// splitServiceName returns the cluster and the name of the service.
+// The cluster of a service ARN (arn:aws:ecs:<region>:<account>:service/<cluster>/<name>) takes precedence.
+func splitServiceName(service, cluster string) (string, string) {
+	if !strings.HasPrefix(service, "arn:") {
+		return cluster, service
+	}
+	i := strings.Index(service, ":service/")
+	if i < 0 {
+		return cluster, service
+	}
+	if parts := strings.Split(service[i+len(":service/"):], "/"); len(parts) == 2 {
+		return parts[0], service
+	}
+	return cluster, service
+}
+
+// formatCapacityProviders converts a capacity provider strategy into the CAPACITY_PROVIDERS format.
+func formatCapacityProviders(strategy []*ecs.CapacityProviderStrategyItem) []string {
+	res := make([]string, len(strategy))
+	for i, item := range strategy {
+		s := fmt.Sprintf("%s:%d", aws.StringValue(item.CapacityProvider), aws.Int64Value(item.Weight))
+		if base := aws.Int64Value(item.Base); base > 0 {
+			s += fmt.Sprintf(":base=%d", base)
+		}
+		res[i] = s
+	}
+	return res
+}
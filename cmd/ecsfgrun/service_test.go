@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+type describeServicesECS struct {
+	mockedECS
+	dsresp  ecs.DescribeServicesOutput
+	cluster *string
+}
+
+func (m *describeServicesECS) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	m.cluster = input.Cluster
+	return &m.dsresp, m.err
+}
+
+func TestApplyLikeService(t *testing.T) {
+	m := describeServicesECS{dsresp: ecs.DescribeServicesOutput{Services: []*ecs.Service{{
+		ServiceName: aws.String("my-svc"),
+		ClusterArn:  aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/prod"),
+		Status:      aws.String("ACTIVE"),
+		CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(3), Base: aws.Int64(1)},
+			{CapacityProvider: aws.String("FARGATE"), Weight: aws.Int64(1)},
+		},
+		NetworkConfiguration: &ecs.NetworkConfiguration{AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+			AssignPublicIp: aws.String("DISABLED"),
+			SecurityGroups: []*string{aws.String("sg-1")},
+			Subnets:        []*string{aws.String("subnet-1"), aws.String("subnet-2")},
+		}},
+		PlatformVersion: aws.String("1.4.0"),
+		TaskDefinition:  aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/my-svc:12"),
+	}}}}
+	env, err := applyLikeService(&m, environments{
+		LikeService:    "arn:aws:ecs:us-east-1:123456789012:service/prod/my-svc",
+		LaunchType:     "FARGATE",
+		AssignPublicIP: true,
+		SecurityGroups: []string{"sg-9"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(m.cluster) != "prod" {
+		t.Errorf("applyLikeService() DescribeServices cluster = %v, want:prod", aws.StringValue(m.cluster))
+	}
+	if env.Cluster != "arn:aws:ecs:us-east-1:123456789012:cluster/prod" {
+		t.Errorf("applyLikeService() Cluster = %s", env.Cluster)
+	}
+	if !reflect.DeepEqual(env.CapacityProviders, []string{"FARGATE_SPOT:3:base=1", "FARGATE:1"}) {
+		t.Errorf("applyLikeService() CapacityProviders = %v", env.CapacityProviders)
+	}
+	if !reflect.DeepEqual(env.Subnets, []string{"subnet-1", "subnet-2"}) || !reflect.DeepEqual(env.SecurityGroups, []string{"sg-9"}) {
+		t.Errorf("applyLikeService() = Subnets:%v, SecurityGroups:%v", env.Subnets, env.SecurityGroups)
+	}
+	if env.AssignPublicIP || env.PlatformVersion != "1.4.0" || env.TaskDefinition != "arn:aws:ecs:us-east-1:123456789012:task-definition/my-svc:12" {
+		t.Errorf("applyLikeService() = AssignPublicIP:%v, PlatformVersion:%s, TaskDefinition:%s", env.AssignPublicIP, env.PlatformVersion, env.TaskDefinition)
+	}
+
+	m = describeServicesECS{dsresp: ecs.DescribeServicesOutput{Failures: []*ecs.Failure{{Reason: aws.String("MISSING")}}}}
+	_, err = applyLikeService(&m, environments{LikeService: "hoge", Cluster: "dev"})
+	if err == nil || !strings.Contains(err.Error(), "service hoge is missing in cluster dev") {
+		t.Errorf("applyLikeService() = err:%v", err)
+	}
+}
+
+func TestSplitServiceName(t *testing.T) {
+	var vtests = []struct {
+		service, cluster string
+		expected         string
+	}{
+		{"my-svc", "dev", "dev"},
+		{"arn:aws:ecs:us-east-1:123456789012:service/prod/my-svc", "dev", "prod"},
+		{"arn:aws:ecs:us-east-1:123456789012:service/my-svc", "dev", "dev"},
+	}
+	for i, vt := range vtests {
+		cluster, name := splitServiceName(vt.service, vt.cluster)
+		if cluster != vt.expected || name != vt.service {
+			t.Errorf("err %d:splitServiceName() = %s, %s, want:%s", i, cluster, name, vt.expected)
+		}
+	}
+}
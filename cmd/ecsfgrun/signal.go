@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// interruptCleanups are run when ecsfgrun gets SIGINT or SIGTERM, because the deferred
+// cleanups do not run when the process is killed by a signal.
+var interruptCleanups = &cleanupRegistry{funcs: map[int]func(){}}
+
+type cleanupRegistry struct {
+	mu      sync.Mutex
+	next    int
+	funcs   map[int]func()
+	started bool
+}
+
+// onInterrupt registers f to run on SIGINT or SIGTERM. ecsfgrun exits after the registered
+// functions finish. The returned function unregisters f.
+func onInterrupt(f func()) (remove func()) {
+	r := interruptCleanups
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		r.started = true
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			s := <-sig
+			log.Printf("received %s: clean up and exit", s)
+			r.run()
+			code := 130
+			if s == syscall.SIGTERM {
+				code = 143
+			}
+			os.Exit(code)
+		}()
+	}
+	id := r.next
+	r.next++
+	r.funcs[id] = f
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.funcs, id)
+	}
+}
+
+// run runs the registered functions concurrently and waits for them.
+func (r *cleanupRegistry) run() {
+	r.mu.Lock()
+	funcs := make([]func(), 0, len(r.funcs))
+	for _, f := range r.funcs {
+		funcs = append(funcs, f)
+	}
+	r.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, f := range funcs {
+		wg.Add(1)
+		go func(f func()) {
+			defer wg.Done()
+			f()
+		}(f)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestOnInterrupt(t *testing.T) {
+	var first, second int32
+	removeFirst := onInterrupt(func() { atomic.AddInt32(&first, 1) })
+	removeSecond := onInterrupt(func() { atomic.AddInt32(&second, 1) })
+	defer removeSecond()
+	removeFirst()
+	interruptCleanups.run()
+	if first != 0 || second != 1 {
+		t.Errorf("run() called first %d times and second %d times, want 0 and 1", first, second)
+	}
+}
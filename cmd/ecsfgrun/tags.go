@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/go-ini/ini"
+)
+
+const (
+	configPath = ".ecsfgrun"
+	iniTags    = "tags"
+
+	tagLaunchedBy = "ecsfgrun:launched-by"
+	tagHost       = "ecsfgrun:host"
+	tagVersion    = "ecsfgrun:version"
+
+	maxTags        = 50
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+// getTags returns the tags of the task. Later sources take precedence:
+// the automatic tags, the [tags] section of the config file, then TAGS and -tag.
+func getTags(env environments) ([]*ecs.Tag, error) {
+	tags := map[string]string{}
+	if env.AutoTags {
+		for k, v := range getAutoTags() {
+			tags[k] = v
+		}
+	}
+	fileTags, err := getConfigTags(awsFilePath(env.ConfigFile, configPath, env.Home))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range fileTags {
+		tags[k] = v
+	}
+	for _, tag := range env.Tags {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, fmt.Errorf("invalid tag %q: use key=value", tag)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	if err := validateTags(tags); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]*ecs.Tag, len(keys))
+	for i, k := range keys {
+		res[i] = &ecs.Tag{Key: aws.String(k), Value: aws.String(tags[k])}
+	}
+	return res, nil
+}
+
+// getAutoTags records who launched the task, from which host, and the version of ecsfgrun.
+func getAutoTags() map[string]string {
+	tags := map[string]string{tagVersion: version}
+	if name := getUserName(); len(name) > 0 {
+		tags[tagLaunchedBy] = name
+	}
+	if host, err := os.Hostname(); err == nil {
+		tags[tagHost] = host
+	}
+	return tags
+}
+
+func getUserName() string {
+	if u, err := user.Current(); err == nil && len(u.Username) > 0 {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// getConfigTags reads the [tags] section of the config file. A missing file is not an error.
+func getConfigTags(path string) (map[string]string, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	config, err := ini.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file. err:%s", err)
+	}
+	sec, err := config.GetSection(iniTags)
+	if err != nil {
+		return nil, nil
+	}
+	return sec.KeysHash(), nil
+}
+
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("too many tags: %d, the maximum is %d", len(tags), maxTags)
+	}
+	for k, v := range tags {
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			return fmt.Errorf("invalid tag %q: the aws: prefix is reserved", k)
+		}
+		if len(k) > maxTagKeyLen {
+			return fmt.Errorf("invalid tag %q: the key is longer than %d", k, maxTagKeyLen)
+		}
+		if len(v) > maxTagValueLen {
+			return fmt.Errorf("invalid tag %q: the value is longer than %d", k, maxTagValueLen)
+		}
+	}
+	return nil
+}
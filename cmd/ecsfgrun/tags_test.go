@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const testHomeC = "./test/c"
+
+func TestGetTags(t *testing.T) {
+	var vtests = []struct {
+		env      environments
+		expected map[string]string
+		err      bool
+	}{
+		{
+			environments{Home: testHomeA},
+			map[string]string{},
+			false,
+		},
+		{
+			environments{Home: testHomeC, Tags: []string{"env=prod", "job=backfill"}},
+			map[string]string{"team": "hoge", "env": "prod", "job": "backfill"},
+			false,
+		},
+		{
+			environments{Home: testHomeA, AutoTags: true},
+			map[string]string{tagVersion: version},
+			false,
+		},
+		{
+			environments{Home: testHomeA, Tags: []string{"hoge"}},
+			nil,
+			true,
+		},
+		{
+			environments{Home: testHomeA, Tags: []string{"aws:hoge=fuga"}},
+			nil,
+			true,
+		},
+	}
+	for i, vt := range vtests {
+		res, err := getTags(vt.env)
+		if (err != nil) != vt.err {
+			t.Errorf("err %d:getTags() = err:%v, want err:%v", i, err, vt.err)
+			continue
+		}
+		tags := map[string]string{}
+		for _, tag := range res {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		for k, v := range vt.expected {
+			if tags[k] != v {
+				t.Errorf("err %d:getTags() = %v, want %s=%s", i, tags, k, v)
+			}
+		}
+		if !vt.env.AutoTags && len(tags) != len(vt.expected) {
+			t.Errorf("err %d:getTags() = %v, want:%v", i, tags, vt.expected)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+const (
+	// the status is polled more slowly while it does not change
+	minStatusInterval = time.Second
+	maxStatusInterval = 10 * time.Second
+	// the logs are read more slowly while there are no new events
+	minLogInterval = 500 * time.Millisecond
+	maxLogInterval = 5 * time.Second
+)
+
+// taskStatus is the final status of the task found by pollStatus.
+type taskStatus struct {
+	task      *ecs.Task
+	container *ecs.Container
+	err       error
+}
+
+// pollStatus polls the task until it stops, and sends the final status to result.
+// started is closed when the container leaves PENDING. The interval grows while the status
+// stays the same and is reset when it changes. An error ends the polling, so throttling and
+// the MISSING task right after RunTask are retried by the client (see retryingECS).
+func pollStatus(ctx context.Context, client ecsiface.ECSAPI, input ecs.DescribeTasksInput, env environments, started chan<- struct{}, result chan<- taskStatus) {
+	interval := minStatusInterval
+	last := ""
+	for {
+		task, c, err := getTaskInfo(client, &input)
+		if err != nil {
+			result <- taskStatus{err: err}
+			return
+		}
+		status := aws.StringValue(c.LastStatus)
+		if status == "PENDING" {
+			if env.ShowPending {
+				log.Printf("Task Status: %s", status)
+			}
+		} else if started != nil {
+			close(started)
+			started = nil
+		}
+		if status == "STOPPED" {
+			result <- taskStatus{task: task, container: c}
+			return
+		}
+		if status == last {
+			interval = nextInterval(interval, maxStatusInterval)
+		} else {
+			interval = minStatusInterval
+		}
+		last = status
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// tailLogs reads the logs continuously after the container starts. The interval grows while
+// there are no new events. When stopped is closed, it drains the trailing events.
+func tailLogs(ctx context.Context, w io.Writer, client cloudwatchlogsiface.CloudWatchLogsAPI, input cloudwatchlogs.GetLogEventsInput, env environments, started, stopped <-chan struct{}) {
+	select {
+	case <-started:
+	case <-ctx.Done():
+		return
+	}
+	cw := &countWriter{w: w}
+	interval := minLogInterval
+	for {
+		if readLogs(client, cw, &input, env) {
+			interval = minLogInterval
+		} else {
+			interval = nextInterval(interval, maxLogInterval)
+		}
+		select {
+		case <-stopped:
+			drainLogs(client, cw, input, env)
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// readLogs reads the new events and advances input. It reports whether there were any.
+func readLogs(client cloudwatchlogsiface.CloudWatchLogsAPI, cw *countWriter, input *cloudwatchlogs.GetLogEventsInput, env environments) bool {
+	written := cw.n
+	next, err := getLogs(client, cw, *input, env)
+	if err != nil {
+		log.Printf("getLogs err:%s", err)
+		return false
+	}
+	input.NextToken = next
+	return cw.n > written
+}
+
+// drainLogs reads the events which CloudWatch Logs ingests after the task stopped. It keeps reading
+// until no new events arrive for LOG_DRAIN_QUIET, and gives up after LOG_DRAIN_TIMEOUT.
+func drainLogs(client cloudwatchlogsiface.CloudWatchLogsAPI, cw *countWriter, input cloudwatchlogs.GetLogEventsInput, env environments) {
+	start := time.Now()
+	lastEvent := start
+	for {
+		if readLogs(client, cw, &input, env) {
+			lastEvent = time.Now()
+		}
+		if time.Since(lastEvent) >= env.LogDrainQuiet {
+			return
+		}
+		if time.Since(start) >= env.LogDrainTimeout {
+			log.Printf("gave up reading the logs after %s: the last lines may be missing", env.LogDrainTimeout)
+			return
+		}
+		time.Sleep(minLogInterval)
+	}
+}
+
+func nextInterval(interval, max time.Duration) time.Duration {
+	interval = interval * 3 / 2
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+// countWriter counts the bytes written.
+type countWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// statusSequenceECS answers DescribeTasks with the statuses in order, then repeats the last one.
+type statusSequenceECS struct {
+	mockedECS
+	mu       sync.Mutex
+	statuses []string
+	calls    int
+}
+
+func (m *statusSequenceECS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	status := m.statuses[m.calls]
+	if m.calls < len(m.statuses)-1 {
+		m.calls++
+	}
+	return &ecs.DescribeTasksOutput{Tasks: []*ecs.Task{{
+		TaskArn:    aws.String("arn"),
+		Containers: []*ecs.Container{{Name: aws.String("hoge"), LastStatus: aws.String(status), ExitCode: aws.Int64(3)}},
+	}}}, nil
+}
+
+// streamingCWL returns a new event on every call until the events run out.
+type streamingCWL struct {
+	mockedCWL
+	mu     sync.Mutex
+	events []string
+}
+
+func (m *streamingCWL) GetLogEvents(input *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := &cloudwatchlogs.GetLogEventsOutput{NextForwardToken: input.NextToken}
+	if len(m.events) > 0 {
+		res.Events = []*cloudwatchlogs.OutputLogEvent{{Timestamp: aws.Int64(0), Message: aws.String(m.events[0])}}
+		m.events = m.events[1:]
+		res.NextForwardToken = aws.String(time.Now().String())
+	}
+	return res, nil
+}
+
+func TestReadLog(t *testing.T) {
+	m := &statusSequenceECS{statuses: []string{"PENDING", "RUNNING", "RUNNING", "STOPPED"}}
+	l := &streamingCWL{events: []string{"line1", "line2", "line3"}}
+	var b bytes.Buffer
+	code, err := readLog(&b, l, m, cloudwatchlogs.GetLogEventsInput{}, ecs.DescribeTasksInput{}, environments{})
+	if code != 3 || err != nil {
+		t.Errorf("readLog() = %d, %v, want:3, nil", code, err)
+	}
+	if b.String() != "line1\nline2\nline3\n" {
+		t.Errorf("readLog() output = %q", b.String())
+	}
+
+	m = &statusSequenceECS{mockedECS: mockedECS{err: errors.New("test error")}}
+	code, err = readLog(&b, l, m, cloudwatchlogs.GetLogEventsInput{}, ecs.DescribeTasksInput{}, environments{})
+	if code != 2 || err == nil {
+		t.Errorf("readLog() = %d, %v, want:2, test error", code, err)
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	if d := nextInterval(time.Second, 10*time.Second); d != 1500*time.Millisecond {
+		t.Errorf("nextInterval() = %s, want:1.5s", d)
+	}
+	if d := nextInterval(8*time.Second, 10*time.Second); d != 10*time.Second {
+		t.Errorf("nextInterval() = %s, want:10s", d)
+	}
+}
+
+// laggingCWL returns the event after the given number of empty responses.
+type laggingCWL struct {
+	mockedCWL
+	lag   int
+	calls int
+}
+
+func (m *laggingCWL) GetLogEvents(input *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	m.calls++
+	if m.calls != m.lag+1 {
+		token := aws.StringValue(input.NextToken)
+		if len(token) == 0 {
+			token = "first"
+		}
+		return &cloudwatchlogs.GetLogEventsOutput{NextForwardToken: aws.String(token)}, nil
+	}
+	return &cloudwatchlogs.GetLogEventsOutput{
+		Events:           []*cloudwatchlogs.OutputLogEvent{{Message: aws.String("last line")}},
+		NextForwardToken: aws.String("next"),
+	}, nil
+}
+
+func TestDrainLogs(t *testing.T) {
+	var vtests = []struct {
+		lag      int
+		env      environments
+		expected string
+		maxTime  time.Duration
+	}{
+		// the event arrives within the quiet period, then the drain ends without waiting for the timeout
+		{2, environments{LogDrainQuiet: time.Second, LogDrainTimeout: 10 * time.Second}, "last line\n", 4 * time.Second},
+		// no events arrive for the quiet period
+		{10, environments{LogDrainQuiet: time.Second, LogDrainTimeout: 10 * time.Second}, "", 2 * time.Second},
+		// the drain gives up at the timeout
+		{10, environments{LogDrainQuiet: 10 * time.Second, LogDrainTimeout: time.Second}, "", 2 * time.Second},
+	}
+	for i, vt := range vtests {
+		m := &laggingCWL{lag: vt.lag}
+		var b bytes.Buffer
+		start := time.Now()
+		drainLogs(m, &countWriter{w: &b}, cloudwatchlogs.GetLogEventsInput{}, vt.env)
+		if b.String() != vt.expected {
+			t.Errorf("err %d:drainLogs() = %q, want:%q", i, b.String(), vt.expected)
+		}
+		if elapsed := time.Since(start); elapsed > vt.maxTime {
+			t.Errorf("err %d:drainLogs() took %s, want %s or less", i, elapsed, vt.maxTime)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+var placeholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// localTaskDefinition answers DescribeTaskDefinition with a task definition which is not registered yet.
+type localTaskDefinition struct {
+	ecsiface.ECSAPI
+	def *ecs.TaskDefinition
+}
+
+func (l *localTaskDefinition) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: l.def}, nil
+}
+
+// createRegisterParam returns the input to register a temporary task definition revision,
+// or nil if the task definition can be run as it is. A revision is needed for IMAGES and secrets.
+func createRegisterParam(client ecsiface.ECSAPI, env environments, withOverrideVars bool) (*ecs.RegisterTaskDefinitionInput, error) {
+	secrets, err := getSecrets(env, withOverrideVars)
+	if err != nil {
+		return nil, err
+	}
+	if env.Verbose && len(secrets) > 0 {
+		names := make([]string, len(secrets))
+		for i, s := range secrets {
+			names[i] = aws.StringValue(s.Name)
+		}
+		log.Printf("secrets: %s", strings.Join(names, ", "))
+	}
+	var input *ecs.RegisterTaskDefinitionInput
+	switch {
+	case len(env.TaskDefinitionFile) > 0:
+		input, err = loadTaskDefinitionFile(env.TaskDefinitionFile)
+	case (len(env.Images) > 0 || len(secrets) > 0) && len(env.TaskDefinition) > 0:
+		input, err = describeRegisterParam(client, env.TaskDefinition)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	container := aws.StringValue(getTargetContainer(getTaskDefinitionOf(input)))
+	if err := swapImages(input, env.Images, container); err != nil {
+		return nil, err
+	}
+	// container overrides cannot carry secrets, so the temporary revision does
+	if err := addSecrets(input, secrets, container); err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+func describeRegisterParam(client ecsiface.ECSAPI, taskDef string) (*ecs.RegisterTaskDefinitionInput, error) {
+	definition, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &taskDef,
+		Include:        []*string{aws.String(ecs.TaskDefinitionFieldTags)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if definition.TaskDefinition == nil {
+		return nil, fmt.Errorf("task definition %s is not found", taskDef)
+	}
+	return cloneTaskDefinition(definition.TaskDefinition, definition.Tags), nil
+}
+
+// loadTaskDefinitionFile reads the input of RegisterTaskDefinition from a JSON file.
+func loadTaskDefinitionFile(path string) (*ecs.RegisterTaskDefinitionInput, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err = expandPlaceholders(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	var input ecs.RegisterTaskDefinitionInput
+	if err := json.Unmarshal(b, &input); err != nil {
+		return nil, fmt.Errorf("failed to parse %s err:%s", path, err)
+	}
+	if len(aws.StringValue(input.Family)) == 0 {
+		return nil, fmt.Errorf("%s: family is required", path)
+	}
+	if len(input.ContainerDefinitions) == 0 {
+		return nil, fmt.Errorf("%s: containerDefinitions is required", path)
+	}
+	return &input, nil
+}
+
+// expandPlaceholders replaces ${VAR} with the environment variable escaped for a JSON string.
+func expandPlaceholders(b []byte) ([]byte, error) {
+	var missing []string
+	res := placeholderRe.ReplaceAllFunc(b, func(m []byte) []byte {
+		name := string(placeholderRe.FindSubmatch(m)[1])
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		quoted, _ := json.Marshal(v) // nolint errcheck
+		return quoted[1 : len(quoted)-1]
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables are not set: %s", strings.Join(missing, ", "))
+	}
+	return res, nil
+}
+
+// getTaskDefinitionOf returns the task definition that input will register.
+func getTaskDefinitionOf(input *ecs.RegisterTaskDefinitionInput) *ecs.TaskDefinition {
+	return &ecs.TaskDefinition{
+		ContainerDefinitions:    input.ContainerDefinitions,
+		Cpu:                     input.Cpu,
+		EphemeralStorage:        input.EphemeralStorage,
+		ExecutionRoleArn:        input.ExecutionRoleArn,
+		Family:                  input.Family,
+		InferenceAccelerators:   input.InferenceAccelerators,
+		IpcMode:                 input.IpcMode,
+		Memory:                  input.Memory,
+		NetworkMode:             input.NetworkMode,
+		PidMode:                 input.PidMode,
+		PlacementConstraints:    input.PlacementConstraints,
+		ProxyConfiguration:      input.ProxyConfiguration,
+		RequiresCompatibilities: input.RequiresCompatibilities,
+		RuntimePlatform:         input.RuntimePlatform,
+		TaskRoleArn:             input.TaskRoleArn,
+		Volumes:                 input.Volumes,
+	}
+}
+
+// cloneTaskDefinition converts def into an input to register a new revision of the same family.
+func cloneTaskDefinition(def *ecs.TaskDefinition, tags []*ecs.Tag) *ecs.RegisterTaskDefinitionInput {
+	containers := make([]*ecs.ContainerDefinition, len(def.ContainerDefinitions))
+	for i, c := range def.ContainerDefinitions {
+		container := *c
+		containers[i] = &container
+	}
+	input := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    containers,
+		Cpu:                     def.Cpu,
+		EphemeralStorage:        def.EphemeralStorage,
+		ExecutionRoleArn:        def.ExecutionRoleArn,
+		Family:                  def.Family,
+		InferenceAccelerators:   def.InferenceAccelerators,
+		IpcMode:                 def.IpcMode,
+		Memory:                  def.Memory,
+		NetworkMode:             def.NetworkMode,
+		PidMode:                 def.PidMode,
+		PlacementConstraints:    def.PlacementConstraints,
+		ProxyConfiguration:      def.ProxyConfiguration,
+		RequiresCompatibilities: def.RequiresCompatibilities,
+		RuntimePlatform:         def.RuntimePlatform,
+		TaskRoleArn:             def.TaskRoleArn,
+		Volumes:                 def.Volumes,
+	}
+	if len(tags) > 0 {
+		input.Tags = tags
+	}
+	return input
+}
+
+// swapImages replaces the images of the containers. Each image is "container=image",
+// or just "image" for the container that receives the overrides.
+func swapImages(input *ecs.RegisterTaskDefinitionInput, images []string, defaultContainer string) error {
+	for _, image := range images {
+		name, uri := defaultContainer, image
+		if kv := strings.SplitN(image, "=", 2); len(kv) == 2 {
+			name, uri = kv[0], kv[1]
+		}
+		if len(uri) == 0 {
+			return fmt.Errorf("invalid image %q: use container=repository:tag", image)
+		}
+		found := false
+		for _, c := range input.ContainerDefinitions {
+			if aws.StringValue(c.Name) == name {
+				c.Image = aws.String(uri)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid image %q: container %q is not found in the task definition", image, name)
+		}
+	}
+	return nil
+}
+
+// registerTaskDefinition registers input and returns the ARN of the new revision.
+func registerTaskDefinition(client ecsiface.ECSAPI, input *ecs.RegisterTaskDefinitionInput) (string, error) {
+	res, err := client.RegisterTaskDefinition(input)
+	if err != nil {
+		return "", err
+	}
+	if res.TaskDefinition == nil {
+		return "", fmt.Errorf("failed to register task definition %s", aws.StringValue(input.Family))
+	}
+	arn := aws.StringValue(res.TaskDefinition.TaskDefinitionArn)
+	log.Printf("registered task definition %s", arn)
+	return arn, nil
+}
+
+// scheduleCleanup returns the function which cleans up the temporary revision, to be deferred.
+// The revision is registered in the family of the task definition and becomes its latest ACTIVE
+// revision, so it is also cleaned up when ecsfgrun is interrupted by SIGINT or SIGTERM.
+func scheduleCleanup(client ecsiface.ECSAPI, arn string, env environments) func() {
+	cleanup := func() { cleanupTaskDefinition(client, arn, env) }
+	remove := onInterrupt(cleanup)
+	return func() {
+		remove()
+		cleanup()
+	}
+}
+
+// cleanupTaskDefinition deregisters the temporary revision, and deletes it if DELETE_TASK_DEFINITION is set.
+func cleanupTaskDefinition(client ecsiface.ECSAPI, arn string, env environments) {
+	if _, err := client.DeregisterTaskDefinition(&ecs.DeregisterTaskDefinitionInput{TaskDefinition: &arn}); err != nil {
+		log.Printf("failed to deregister task definition %s err:%s", arn, err)
+		return
+	}
+	if !env.DeleteTaskDefinition {
+		return
+	}
+	res, err := client.DeleteTaskDefinitions(&ecs.DeleteTaskDefinitionsInput{TaskDefinitions: []*string{&arn}})
+	if err != nil {
+		log.Printf("failed to delete task definition %s err:%s", arn, err)
+		return
+	}
+	for _, failure := range res.Failures {
+		log.Printf("failed to delete task definition %s: %s", arn, aws.StringValue(failure.Reason))
+	}
+}
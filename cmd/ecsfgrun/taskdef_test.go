@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const testTaskDefFile = "./test/taskdef.json"
+
+type taskDefECS struct {
+	mockedECS
+	registered   *ecs.RegisterTaskDefinitionInput
+	deregistered []string
+	deleted      []string
+}
+
+func (m *taskDefECS) RegisterTaskDefinition(input *ecs.RegisterTaskDefinitionInput) (*ecs.RegisterTaskDefinitionOutput, error) {
+	m.registered = input
+	return &ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/hoge:2")},
+	}, m.err
+}
+
+func (m *taskDefECS) DeregisterTaskDefinition(input *ecs.DeregisterTaskDefinitionInput) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	m.deregistered = append(m.deregistered, aws.StringValue(input.TaskDefinition))
+	return &ecs.DeregisterTaskDefinitionOutput{}, m.err
+}
+
+func (m *taskDefECS) DeleteTaskDefinitions(input *ecs.DeleteTaskDefinitionsInput) (*ecs.DeleteTaskDefinitionsOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValueSlice(input.TaskDefinitions)...)
+	return &ecs.DeleteTaskDefinitionsOutput{}, m.err
+}
+
+func TestCreateRegisterParam(t *testing.T) {
+	m := taskDefECS{mockedECS: mockedECS{
+		dtdresp: ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{
+				Family: aws.String("hoge"),
+				Cpu:    aws.String("256"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("app"), Image: aws.String("app:1")},
+					{Name: aws.String("sidecar"), Image: aws.String("sidecar:1")},
+				},
+			},
+			Tags: []*ecs.Tag{{Key: aws.String("team"), Value: aws.String("hoge")}},
+		},
+	}}
+	var vtests = []struct {
+		images   []string
+		expected []string
+		err      bool
+	}{
+		{nil, nil, false},
+		{[]string{"app=app:2"}, []string{"app:2", "sidecar:1"}, false},
+		{[]string{"sidecar:2"}, []string{"app:1", "sidecar:2"}, false},
+		{[]string{"none=app:2"}, nil, true},
+		{[]string{"app="}, nil, true},
+	}
+	for i, vt := range vtests {
+		input, err := createRegisterParam(&m, environments{TaskDefinition: "hoge:1", Images: vt.images}, false)
+		if (err != nil) != vt.err {
+			t.Errorf("err %d:createRegisterParam() = err:%v, want err:%v", i, err, vt.err)
+			continue
+		}
+		if vt.expected == nil {
+			if input != nil {
+				t.Errorf("err %d:createRegisterParam() = %v, want:nil", i, input)
+			}
+			continue
+		}
+		if aws.StringValue(input.Family) != "hoge" || aws.StringValue(input.Cpu) != "256" || len(input.Tags) != 1 {
+			t.Errorf("err %d:createRegisterParam() = %v", i, input)
+		}
+		for j, c := range input.ContainerDefinitions {
+			if aws.StringValue(c.Image) != vt.expected[j] {
+				t.Errorf("err %d:createRegisterParam() image = %s, want:%s", i, aws.StringValue(c.Image), vt.expected[j])
+			}
+		}
+	}
+	if aws.StringValue(m.dtdresp.TaskDefinition.ContainerDefinitions[0].Image) != "app:1" {
+		t.Error("createRegisterParam() modified the described task definition")
+	}
+}
+
+func TestRegisterAndCleanupTaskDefinition(t *testing.T) {
+	var vtests = []struct {
+		delete   bool
+		expected int
+	}{
+		{false, 0},
+		{true, 1},
+	}
+	for i, vt := range vtests {
+		m := taskDefECS{}
+		arn, err := registerTaskDefinition(&m, &ecs.RegisterTaskDefinitionInput{Family: aws.String("hoge")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cleanupTaskDefinition(&m, arn, environments{DeleteTaskDefinition: vt.delete})
+		if len(m.deregistered) != 1 || m.deregistered[0] != arn {
+			t.Errorf("err %d:cleanupTaskDefinition() deregistered = %v, want:%s", i, m.deregistered, arn)
+		}
+		if len(m.deleted) != vt.expected {
+			t.Errorf("err %d:cleanupTaskDefinition() deleted = %v", i, m.deleted)
+		}
+	}
+}
+
+func TestLoadTaskDefinitionFile(t *testing.T) {
+	os.Setenv("ECSFGRUN_TEST_IMAGE", "app:1")
+	os.Setenv("ECSFGRUN_TEST_MESSAGE", `say "hello"`)
+	defer os.Unsetenv("ECSFGRUN_TEST_IMAGE")
+	defer os.Unsetenv("ECSFGRUN_TEST_MESSAGE")
+	input, err := createRegisterParam(&taskDefECS{}, environments{TaskDefinitionFile: testTaskDefFile, Images: []string{"app=app:2"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(input.Family) != "hoge" || aws.StringValue(input.RuntimePlatform.CpuArchitecture) != "ARM64" {
+		t.Errorf("loadTaskDefinitionFile() = %v", input)
+	}
+	c := input.ContainerDefinitions[0]
+	if aws.StringValue(c.Image) != "app:2" {
+		t.Errorf("loadTaskDefinitionFile() image = %s, want:app:2", aws.StringValue(c.Image))
+	}
+	if aws.StringValue(c.Environment[0].Value) != `say "hello"` {
+		t.Errorf("loadTaskDefinitionFile() environment = %s", aws.StringValue(c.Environment[0].Value))
+	}
+	if aws.StringValue(c.LogConfiguration.Options["awslogs-group"]) != "/ecs/hoge" {
+		t.Errorf("loadTaskDefinitionFile() logConfiguration = %v", c.LogConfiguration)
+	}
+
+	os.Unsetenv("ECSFGRUN_TEST_MESSAGE")
+	_, err = loadTaskDefinitionFile(testTaskDefFile)
+	if err == nil || !strings.Contains(err.Error(), "ECSFGRUN_TEST_MESSAGE") {
+		t.Errorf("loadTaskDefinitionFile() = err:%v, want missing ECSFGRUN_TEST_MESSAGE", err)
+	}
+}
+
+func TestLocalTaskDefinition(t *testing.T) {
+	def := &ecs.TaskDefinition{Family: aws.String("hoge")}
+	client := &localTaskDefinition{ECSAPI: &mockedECS{}, def: def}
+	res, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String("hoge")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TaskDefinition != def {
+		t.Errorf("DescribeTaskDefinition() = %v, want:%v", res.TaskDefinition, def)
+	}
+}
@@ -0,0 +1,6 @@
+#!/bin/sh
+set -e
+echo "migrate $1"
+for t in users orders; do
+  echo "table: $t"
+done
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+// validationError holds every problem found by the preflight validation.
+type validationError []string
+
+func (e validationError) Error() string {
+	return "invalid settings:\n  - " + strings.Join(e, "\n  - ")
+}
+
+func (e *validationError) add(format string, a ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, a...))
+}
+
+const (
+	minEphemeralStorage     = 21
+	maxEphemeralStorage     = 200
+	minArm64PlatformVersion = "1.4.0"
+)
+
+// fargateMemory is the valid memory range (MiB) and increment for each task cpu value of Fargate.
+// values lists the valid memory instead when they are not evenly spaced.
+// see: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-cpu-memory-error.html
+var fargateMemory = map[int64]struct {
+	min, max, step int64
+	values         []int64
+}{
+	256:   {values: []int64{512, 1024, 2048}},
+	512:   {min: 1024, max: 4096, step: 1024},
+	1024:  {min: 2048, max: 8192, step: 1024},
+	2048:  {min: 4096, max: 16384, step: 1024},
+	4096:  {min: 8192, max: 30720, step: 1024},
+	8192:  {min: 16384, max: 61440, step: 4096},
+	16384: {min: 32768, max: 122880, step: 8192},
+}
+
+// validateRunParam checks the settings and the task definition before RunTask is called.
+func validateRunParam(client ecsiface.ECSAPI, env environments) error {
+	var errs validationError
+	if env.TaskDefinition == "" {
+		errs.add("TASKDEF is required: set the family:revision or full ARN of the task definition")
+		return errs
+	}
+	if useCapacityProviders(env) {
+		if _, err := parseCapacityProviders(env.CapacityProviders); err != nil {
+			errs.add("CAPACITY_PROVIDERS is invalid: %s", err)
+		}
+	} else {
+		switch env.LaunchType {
+		case ecs.LaunchTypeFargate, ecs.LaunchTypeEc2, ecs.LaunchTypeExternal:
+		default:
+			errs.add("LAUNCHTYPE=%q is unknown: use FARGATE, EC2 or EXTERNAL", env.LaunchType)
+		}
+	}
+	if getLaunchType(env) == ecs.LaunchTypeEc2 {
+		if len(env.PlatformVersion) > 0 {
+			errs.add("PLATFORM_VERSION is only supported on FARGATE: unset it for the EC2 launch type")
+		}
+		if env.EphemeralStorage > 0 {
+			errs.add("EPHEMERAL_STORAGE is only supported on FARGATE: unset it for the EC2 launch type")
+		}
+	}
+	if env.EphemeralStorage > 0 && (env.EphemeralStorage < minEphemeralStorage || env.EphemeralStorage > maxEphemeralStorage) {
+		errs.add("EPHEMERAL_STORAGE=%d is out of range: use %d-%d GiB", env.EphemeralStorage, minEphemeralStorage, maxEphemeralStorage)
+	}
+	if len(env.TaskRoleARN) > 0 && !strings.HasPrefix(env.TaskRoleARN, "arn:") {
+		errs.add("TASK_ROLE_ARN=%q is not an ARN: use arn:aws:iam::<account>:role/<name>", env.TaskRoleARN)
+	}
+	if len(env.ExecutionRoleARN) > 0 && !strings.HasPrefix(env.ExecutionRoleARN, "arn:") {
+		errs.add("EXECUTION_ROLE_ARN=%q is not an ARN: use arn:aws:iam::<account>:role/<name>", env.ExecutionRoleARN)
+	}
+	if _, err := parsePlacementConstraints(env.PlacementConstraints); err != nil {
+		errs.add("PLACEMENT_CONSTRAINTS is invalid: %s", err)
+	}
+	if _, err := parsePlacementStrategy(env.PlacementStrategy); err != nil {
+		errs.add("PLACEMENT_STRATEGY is invalid: %s", err)
+	}
+	if getLaunchType(env) == ecs.LaunchTypeFargate && len(env.PlacementConstraints)+len(env.PlacementStrategy) > 0 {
+		errs.add("PLACEMENT_CONSTRAINTS and PLACEMENT_STRATEGY are not supported on FARGATE: use them with the EC2 launch type")
+	}
+	switch env.PropagateTags {
+	case "", ecs.PropagateTagsTaskDefinition, ecs.PropagateTagsService, ecs.PropagateTagsNone:
+	default:
+		errs.add("PROPAGATE_TAGS=%q is unknown: use TASK_DEFINITION or SERVICE", env.PropagateTags)
+	}
+	if _, err := getTags(env); err != nil {
+		errs.add("%s", err)
+	}
+	if _, err := getSecrets(env, true); err != nil {
+		errs.add("%s", err)
+	}
+	if env.Count < 1 {
+		errs.add("COUNT=%d is invalid: use 1 or more", env.Count)
+	}
+	switch env.CommandMode {
+	case "", commandModeExec:
+	case commandModeShell:
+		if len(env.Script) > 0 {
+			errs.add("SCRIPT cannot be used in shell mode: the script is run by SCRIPT_INTERPRETER")
+		}
+	default:
+		errs.add("COMMAND_MODE=%q is unknown: use exec or shell", env.CommandMode)
+	}
+	for _, arn := range env.S3EnvFiles {
+		if !strings.HasPrefix(arn, "arn:") || !strings.HasSuffix(arn, ".env") {
+			errs.add("S3_ENV_FILES %q is invalid: use arn:aws:s3:::<bucket>/<key>.env", arn)
+		}
+	}
+	definition, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: &env.TaskDefinition})
+	if err != nil {
+		return err
+	}
+	validateTaskDefinition(&errs, definition.TaskDefinition, env)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateTaskDefinition(errs *validationError, def *ecs.TaskDefinition, env environments) {
+	if def == nil {
+		errs.add("task definition %s is not found", env.TaskDefinition)
+		return
+	}
+	if len(def.ContainerDefinitions) == 0 {
+		errs.add("task definition %s has no container definitions", env.TaskDefinition)
+	}
+	arch := getCPUArchitecture(def)
+	if len(env.CPUArchitecture) > 0 && env.CPUArchitecture != arch {
+		errs.add("task definition %s runs on %s, but CPU_ARCHITECTURE=%s: set runtimePlatform.cpuArchitecture to %s in the task definition", env.TaskDefinition, arch, env.CPUArchitecture, env.CPUArchitecture)
+	}
+	validateContainerResources(errs, def, env)
+	if len(aws.StringValue(def.ExecutionRoleArn)) == 0 && len(env.ExecutionRoleARN) == 0 {
+		if hasSecrets(def) {
+			errs.add("task definition %s has secrets, but no execution role to read them: set executionRoleArn or EXECUTION_ROLE_ARN", env.TaskDefinition)
+		}
+		if len(env.S3EnvFiles) > 0 {
+			errs.add("S3_ENV_FILES needs an execution role to read the files: set executionRoleArn of task definition %s or EXECUTION_ROLE_ARN", env.TaskDefinition)
+		}
+	}
+	networkMode := getNetworkMode(def)
+	if networkMode == ecs.NetworkModeAwsvpc && len(env.Subnets) == 0 {
+		errs.add("SUBNETS is required for awsvpc network mode: set a comma separated list of subnet IDs")
+	}
+	switch getLaunchType(env) {
+	case ecs.LaunchTypeFargate:
+		if !hasCompatibility(def, ecs.CompatibilityFargate) {
+			errs.add("task definition %s is not compatible with FARGATE: add FARGATE to requiresCompatibilities", env.TaskDefinition)
+		}
+		if networkMode != ecs.NetworkModeAwsvpc {
+			errs.add("task definition %s uses %q network mode: FARGATE requires awsvpc", env.TaskDefinition, networkMode)
+		}
+		validateFargateSize(errs, getTaskCPU(def, env), getTaskMemory(def, env))
+		validateFargatePlatform(errs, def, env)
+	case ecs.LaunchTypeEc2:
+		if !hasCompatibility(def, ecs.CompatibilityEc2) {
+			errs.add("task definition %s is not compatible with EC2: add EC2 to requiresCompatibilities", env.TaskDefinition)
+		}
+	}
+}
+
+// validateContainerResources checks the container overrides against the task level size.
+func validateContainerResources(errs *validationError, def *ecs.TaskDefinition, env environments) {
+	if env.ContainerMemory > 0 && env.ContainerMemoryReserve > env.ContainerMemory {
+		errs.add("CONTAINER_MEMORY_RESERVATION=%d exceeds CONTAINER_MEMORY=%d", env.ContainerMemoryReserve, env.ContainerMemory)
+	}
+	if cpu, err := parseCPU(getTaskCPU(def, env)); err == nil && env.ContainerCPU > cpu {
+		errs.add("CONTAINER_CPU=%d exceeds the task cpu %d: raise TASK_CPU", env.ContainerCPU, cpu)
+	}
+	memory, err := parseMemory(getTaskMemory(def, env))
+	if err != nil {
+		return
+	}
+	if env.ContainerMemory > memory {
+		errs.add("CONTAINER_MEMORY=%d exceeds the task memory %d: raise TASK_MEMORY", env.ContainerMemory, memory)
+	}
+	if env.ContainerMemoryReserve > memory {
+		errs.add("CONTAINER_MEMORY_RESERVATION=%d exceeds the task memory %d: raise TASK_MEMORY", env.ContainerMemoryReserve, memory)
+	}
+}
+
+// getTaskCPU returns the task cpu after the override.
+func getTaskCPU(def *ecs.TaskDefinition, env environments) string {
+	if len(env.TaskCPU) > 0 {
+		return env.TaskCPU
+	}
+	return aws.StringValue(def.Cpu)
+}
+
+// getTaskMemory returns the task memory after the override.
+func getTaskMemory(def *ecs.TaskDefinition, env environments) string {
+	if len(env.TaskMemory) > 0 {
+		return env.TaskMemory
+	}
+	return aws.StringValue(def.Memory)
+}
+
+func validateFargateSize(errs *validationError, cpuStr, memoryStr string) {
+	if cpuStr == "" || memoryStr == "" {
+		errs.add("task level cpu and memory are required for FARGATE")
+		return
+	}
+	cpu, err := parseCPU(cpuStr)
+	if err != nil {
+		errs.add("invalid task cpu %q: %s", cpuStr, err)
+		return
+	}
+	memory, err := parseMemory(memoryStr)
+	if err != nil {
+		errs.add("invalid task memory %q: %s", memoryStr, err)
+		return
+	}
+	r, ok := fargateMemory[cpu]
+	if !ok {
+		errs.add("task cpu %d is not supported by FARGATE: use 256, 512, 1024, 2048, 4096, 8192 or 16384", cpu)
+		return
+	}
+	if len(r.values) > 0 {
+		for _, v := range r.values {
+			if memory == v {
+				return
+			}
+		}
+		errs.add("task memory %d is not supported with cpu %d on FARGATE: use one of %s MiB", memory, cpu, joinInt64(r.values))
+		return
+	}
+	if memory < r.min || memory > r.max || (memory-r.min)%r.step != 0 {
+		errs.add("task memory %d is not supported with cpu %d on FARGATE: use %d-%d MiB in increments of %d", memory, cpu, r.min, r.max, r.step)
+	}
+}
+
+func joinInt64(values []int64) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(s, ", ")
+}
+
+// validateFargatePlatform checks the runtime platform of the task definition against the Fargate capacity.
+func validateFargatePlatform(errs *validationError, def *ecs.TaskDefinition, env environments) {
+	if getCPUArchitecture(def) == ecs.CPUArchitectureArm64 && len(env.PlatformVersion) > 0 && env.PlatformVersion != "LATEST" && compareVersion(env.PlatformVersion, minArm64PlatformVersion) < 0 {
+		errs.add("task definition %s runs on ARM64, which requires Fargate platform version %s or later: set PLATFORM_VERSION=LATEST", env.TaskDefinition, minArm64PlatformVersion)
+	}
+	if def.RuntimePlatform == nil || strings.HasPrefix(aws.StringValue(def.RuntimePlatform.OperatingSystemFamily), ecs.OSFamilyLinux) {
+		return
+	}
+	strategy, _ := parseCapacityProviders(env.CapacityProviders)
+	for _, item := range strategy {
+		if aws.StringValue(item.CapacityProvider) == capacityProviderFargateSpot {
+			errs.add("task definition %s runs on %s, which is not supported by FARGATE_SPOT: use FARGATE in CAPACITY_PROVIDERS", env.TaskDefinition, aws.StringValue(def.RuntimePlatform.OperatingSystemFamily))
+			return
+		}
+	}
+}
+
+// getCPUArchitecture returns the cpu architecture of the task definition. It defaults to X86_64.
+func getCPUArchitecture(def *ecs.TaskDefinition) string {
+	if def.RuntimePlatform == nil || def.RuntimePlatform.CpuArchitecture == nil {
+		return ecs.CPUArchitectureX8664
+	}
+	return *def.RuntimePlatform.CpuArchitecture
+}
+
+// compareVersion compares dotted version strings like "1.4.0".
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int64
+		if i < len(as) {
+			x, _ = strconv.ParseInt(as[i], 10, 64)
+		}
+		if i < len(bs) {
+			y, _ = strconv.ParseInt(bs[i], 10, 64)
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func hasCompatibility(def *ecs.TaskDefinition, compatibility string) bool {
+	for _, c := range def.RequiresCompatibilities {
+		if aws.StringValue(c) == compatibility {
+			return true
+		}
+	}
+	for _, c := range def.Compatibilities {
+		if aws.StringValue(c) == compatibility {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCPU parses cpu units ("1024") or vCPU ("1 vCPU").
+func parseCPU(s string) (int64, error) {
+	return parseUnit(s, "vcpu", 1024)
+}
+
+// parseMemory parses MiB ("2048") or GB ("2 GB").
+func parseMemory(s string) (int64, error) {
+	return parseUnit(s, "gb", 1024)
+}
+
+func parseUnit(s, unit string, mul float64) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasSuffix(s, unit) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, unit)), 64)
+		if err != nil {
+			return 0, err
+		}
+		return int64(f * mul), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
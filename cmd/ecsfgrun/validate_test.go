@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestValidateRunParam(t *testing.T) {
+	fargateDef := ecs.TaskDefinition{
+		RequiresCompatibilities: []*string{aws.String("FARGATE")},
+		NetworkMode:             aws.String("awsvpc"),
+		Cpu:                     aws.String("256"),
+		Memory:                  aws.String("512"),
+		ContainerDefinitions:    []*ecs.ContainerDefinition{{Name: aws.String("hoge")}},
+	}
+	ec2Def := ecs.TaskDefinition{
+		RequiresCompatibilities: []*string{aws.String("EC2")},
+		NetworkMode:             aws.String("awsvpc"),
+		ContainerDefinitions:    []*ecs.ContainerDefinition{{Name: aws.String("hoge")}},
+	}
+	var vtests = []struct {
+		env      environments
+		def      ecs.TaskDefinition
+		err      error
+		expected []string
+	}{
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "FARGATE", Subnets: []string{"subnet-1"}, Count: 1},
+			fargateDef,
+			nil,
+			nil,
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "FARGATE", Subnets: []string{"subnet-1"}},
+			fargateDef,
+			nil,
+			[]string{"COUNT=0 is invalid"},
+		},
+		{
+			environments{LaunchType: "FARGATE"},
+			fargateDef,
+			nil,
+			[]string{"TASKDEF is required"},
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "FARGATE"},
+			ec2Def,
+			nil,
+			[]string{"SUBNETS is required", "not compatible with FARGATE", "task level cpu and memory are required"},
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "EC2"},
+			ec2Def,
+			nil,
+			[]string{"SUBNETS is required for awsvpc network mode"},
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "EC2", Subnets: []string{"subnet-1"}, Count: 1},
+			ec2Def,
+			nil,
+			nil,
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "EC2", Subnets: []string{"subnet-1"}, Secrets: []string{"hoge"}},
+			ecs.TaskDefinition{
+				RequiresCompatibilities: []*string{aws.String("EC2")},
+				NetworkMode:             aws.String("bridge"),
+				ContainerDefinitions:    []*ecs.ContainerDefinition{{Name: aws.String("hoge"), Secrets: []*ecs.Secret{{Name: aws.String("A"), ValueFrom: aws.String("/a")}}}},
+			},
+			nil,
+			[]string{"invalid secret \"hoge\"", "no execution role"},
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "FARGATE", Subnets: []string{"subnet-1"}},
+			fargateDef,
+			errors.New("test error"),
+			[]string{"test error"},
+		},
+	}
+	for i, vt := range vtests {
+		m := mockedECS{
+			dtdresp: ecs.DescribeTaskDefinitionOutput{TaskDefinition: &vt.def},
+			err:     vt.err,
+		}
+		err := validateRunParam(&m, vt.env)
+		if err == nil {
+			if len(vt.expected) > 0 {
+				t.Errorf("err %d:validateRunParam() = nil, want:%v", i, vt.expected)
+			}
+			continue
+		}
+		if len(vt.expected) == 0 {
+			t.Errorf("err %d:validateRunParam() = err:%s", i, err)
+		}
+		for _, e := range vt.expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("err %d:validateRunParam() = err:%s, want contains:%s", i, err, e)
+			}
+		}
+	}
+}
+
+func TestValidateFargateSize(t *testing.T) {
+	var vtests = []struct {
+		cpu    string
+		memory string
+		valid  bool
+	}{
+		{"256", "512", true},
+		{"256", "1024", true},
+		{"256", "2048", true},
+		{"256", "1536", false},
+		{"256", "4096", false},
+		{"1 vCPU", "2 GB", true},
+		{"1024", "1536", false},
+		{"8192", "20480", true},
+		{"8192", "18432", false},
+		{"300", "512", false},
+		{"abc", "512", false},
+	}
+	for _, vt := range vtests {
+		var errs validationError
+		validateFargateSize(&errs, vt.cpu, vt.memory)
+		if (len(errs) == 0) != vt.valid {
+			t.Errorf("validateFargateSize(%q, %q) = %v, want valid:%v", vt.cpu, vt.memory, errs, vt.valid)
+		}
+	}
+}
+
+func TestValidateRuntimePlatform(t *testing.T) {
+	arm64Def := ecs.TaskDefinition{
+		RequiresCompatibilities: []*string{aws.String("FARGATE")},
+		NetworkMode:             aws.String("awsvpc"),
+		Cpu:                     aws.String("256"),
+		Memory:                  aws.String("512"),
+		ContainerDefinitions:    []*ecs.ContainerDefinition{{Name: aws.String("hoge")}},
+		RuntimePlatform:         &ecs.RuntimePlatform{CpuArchitecture: aws.String("ARM64"), OperatingSystemFamily: aws.String("LINUX")},
+	}
+	windowsDef := arm64Def
+	windowsDef.RuntimePlatform = &ecs.RuntimePlatform{CpuArchitecture: aws.String("X86_64"), OperatingSystemFamily: aws.String("WINDOWS_SERVER_2019_CORE")}
+	var vtests = []struct {
+		env      environments
+		def      ecs.TaskDefinition
+		expected []string
+	}{
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "FARGATE", Subnets: []string{"subnet-1"}, CPUArchitecture: "ARM64", PlatformVersion: "LATEST", Count: 1},
+			arm64Def,
+			nil,
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "FARGATE", Subnets: []string{"subnet-1"}, CPUArchitecture: "X86_64", PlatformVersion: "1.3.0"},
+			arm64Def,
+			[]string{"runs on ARM64, but CPU_ARCHITECTURE=X86_64", "requires Fargate platform version 1.4.0"},
+		},
+		{
+			environments{TaskDefinition: "hoge:1", CapacityProviders: []string{"FARGATE_SPOT"}, Subnets: []string{"subnet-1"}, EphemeralStorage: 300},
+			windowsDef,
+			[]string{"not supported by FARGATE_SPOT", "EPHEMERAL_STORAGE=300 is out of range"},
+		},
+		{
+			environments{TaskDefinition: "hoge:1", LaunchType: "EC2", PlatformVersion: "1.4.0", EphemeralStorage: 30},
+			ecs.TaskDefinition{RequiresCompatibilities: []*string{aws.String("EC2")}, ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("hoge")}}},
+			[]string{"PLATFORM_VERSION is only supported on FARGATE", "EPHEMERAL_STORAGE is only supported on FARGATE"},
+		},
+	}
+	for i, vt := range vtests {
+		m := mockedECS{dtdresp: ecs.DescribeTaskDefinitionOutput{TaskDefinition: &vt.def}}
+		err := validateRunParam(&m, vt.env)
+		if err == nil {
+			if len(vt.expected) > 0 {
+				t.Errorf("err %d:validateRunParam() = nil, want:%v", i, vt.expected)
+			}
+			continue
+		}
+		if len(vt.expected) == 0 {
+			t.Errorf("err %d:validateRunParam() = err:%s", i, err)
+		}
+		for _, e := range vt.expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("err %d:validateRunParam() = err:%s, want contains:%s", i, err, e)
+			}
+		}
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	var vtests = []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.4.0", "1.4.0", 0},
+		{"1.3.0", "1.4.0", -1},
+		{"1.10.0", "1.4.0", 1},
+		{"1.4", "1.4.0", 0},
+	}
+	for _, vt := range vtests {
+		if res := compareVersion(vt.a, vt.b); res != vt.expected {
+			t.Errorf("compareVersion(%q, %q) = %d, want:%d", vt.a, vt.b, res, vt.expected)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	stepSucceeded = "succeeded"
+	stepFailed    = "failed"
+	stepSkipped   = "skipped"
+)
+
+// workflow is a workflow file like:
+//
+//	steps:
+//	  - name: migrate
+//	    task_definition: app:12
+//	    command: [bin/migrate]
+//	    env:
+//	      RAILS_ENV: production
+//	  - name: seed
+//	    command: [bin/seed]
+//	    depends_on: [migrate]
+type workflow struct {
+	Steps []workflowStep `yaml:"steps"`
+}
+
+type workflowStep struct {
+	Name           string            `yaml:"name"`
+	TaskDefinition string            `yaml:"task_definition"`
+	Command        []string          `yaml:"command"`
+	Env            map[string]string `yaml:"env"`
+	DependsOn      []string          `yaml:"depends_on"`
+}
+
+type stepResult struct {
+	status  string
+	code    int
+	err     error
+	elapsed time.Duration
+}
+
+// runWorkflow runs "workflow run <file>". The steps run as soon as the steps they depend on succeed,
+// so independent steps run concurrently. When a step fails, the steps depending on it are skipped.
+func runWorkflow(w io.Writer, ecsSv ecsiface.ECSAPI, logsSv cloudwatchlogsiface.CloudWatchLogsAPI, env environments, args []string) (int, error) {
+	if len(args) != 2 || args[0] != "run" {
+		return 1, fmt.Errorf("usage: ecsfgrun workflow run <workflow.yaml>")
+	}
+	wf, err := loadWorkflow(args[1])
+	if err != nil {
+		return 1, err
+	}
+	results := runSteps(w, wf, func(w io.Writer, step workflowStep) (int, error) {
+		return run(w, ecsSv, logsSv, createStepEnv(env, step), step.Command)
+	})
+	return printWorkflowResults(w, wf, results)
+}
+
+// runSteps runs the steps in the order of the dependencies. The output of a step is written
+// to w at once when the step finishes, so that the outputs of concurrent steps are not mixed.
+func runSteps(w io.Writer, wf *workflow, runStep func(io.Writer, workflowStep) (int, error)) map[string]stepResult {
+	done := map[string]chan struct{}{}
+	for _, step := range wf.Steps {
+		done[step.Name] = make(chan struct{})
+	}
+	results := map[string]stepResult{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, step := range wf.Steps {
+		wg.Add(1)
+		go func(step workflowStep) {
+			defer wg.Done()
+			defer close(done[step.Name])
+			for _, dep := range step.DependsOn {
+				<-done[dep]
+			}
+			mu.Lock()
+			for _, dep := range step.DependsOn {
+				if results[dep].status != stepSucceeded {
+					results[step.Name] = stepResult{status: stepSkipped}
+					mu.Unlock()
+					log.Printf("step %s is skipped: %s %s", step.Name, dep, results[dep].status)
+					return
+				}
+			}
+			mu.Unlock()
+			log.Printf("step %s started", step.Name)
+			var b bytes.Buffer
+			start := time.Now()
+			code, err := runStep(&b, step)
+			res := stepResult{status: stepSucceeded, code: code, err: err, elapsed: time.Since(start).Truncate(time.Second)}
+			if code != 0 || err != nil {
+				res.status = stepFailed
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[step.Name] = res
+			fmt.Fprintf(w, "=== step %s: %s, exit code %d (%s) ===\n", step.Name, res.status, code, res.elapsed)
+			w.Write(b.Bytes()) // nolint errcheck
+			if err != nil {
+				fmt.Fprintf(w, "err:%s\n", err)
+			}
+		}(step)
+	}
+	wg.Wait()
+	return results
+}
+
+// createStepEnv returns the settings of the step. The env of the step takes precedence over ENVS.
+func createStepEnv(env environments, step workflowStep) environments {
+	if len(step.TaskDefinition) > 0 {
+		env.TaskDefinition = step.TaskDefinition
+	}
+	names := make([]string, 0, len(step.Env))
+	for name := range step.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env.Envs = append([]string{}, env.Envs...)
+	for _, name := range names {
+		env.Envs = append(env.Envs, name+"="+step.Env[name])
+	}
+	return env
+}
+
+func loadWorkflow(path string) (*workflow, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var wf workflow
+	if err := yaml.UnmarshalStrict(b, &wf); err != nil {
+		return nil, fmt.Errorf("failed to parse %s err:%s", path, err)
+	}
+	if err := validateWorkflow(&wf); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return &wf, nil
+}
+
+// validateWorkflow checks the names and the dependencies of the steps.
+func validateWorkflow(wf *workflow) error {
+	if len(wf.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	steps := map[string]workflowStep{}
+	for _, step := range wf.Steps {
+		if len(step.Name) == 0 {
+			return fmt.Errorf("a step has no name")
+		}
+		if _, ok := steps[step.Name]; ok {
+			return fmt.Errorf("step %s is defined twice", step.Name)
+		}
+		steps[step.Name] = step
+	}
+	for _, step := range wf.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := steps[dep]; !ok {
+				return fmt.Errorf("step %s depends on unknown step %s", step.Name, dep)
+			}
+		}
+	}
+	// depth first search for a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("the dependencies have a cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range steps[name].DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, step := range wf.Steps {
+		if err := visit(step.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printWorkflowResults prints the table of the steps, and returns the first failure.
+func printWorkflowResults(w io.Writer, wf *workflow, results map[string]stepResult) (int, error) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tEXIT CODE\tELAPSED")
+	var summary exitSummary
+	for _, step := range wf.Steps {
+		res := results[step.Name]
+		if res.status == stepSkipped {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\n", step.Name, res.status)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", step.Name, res.status, res.code, res.elapsed)
+		}
+		summary.add(res.code, res.status != stepSucceeded)
+	}
+	tw.Flush() // nolint errcheck
+	if summary.failed > 0 {
+		return summary.code, fmt.Errorf("%d of %d steps did not succeed", summary.failed, len(wf.Steps))
+	}
+	return 0, nil
+}
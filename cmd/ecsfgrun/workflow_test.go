@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testWorkflowFile = "./test/release.yaml"
+
+func TestLoadWorkflow(t *testing.T) {
+	wf, err := loadWorkflow(testWorkflowFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wf.Steps) != 4 || wf.Steps[0].TaskDefinition != "app:12" || wf.Steps[0].Env["RAILS_ENV"] != "production" || len(wf.Steps[3].DependsOn) != 2 {
+		t.Errorf("loadWorkflow() = %+v", wf)
+	}
+	var vtests = []struct {
+		steps    []workflowStep
+		expected string
+	}{
+		{nil, "no steps"},
+		{[]workflowStep{{Name: "a"}, {Name: "a"}}, "defined twice"},
+		{[]workflowStep{{Name: "a", DependsOn: []string{"b"}}}, "unknown step b"},
+		{[]workflowStep{{Name: "a", DependsOn: []string{"c"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"b"}}}, "cycle: a -> c -> b -> a"},
+	}
+	for i, vt := range vtests {
+		err := validateWorkflow(&workflow{Steps: vt.steps})
+		if err == nil || !strings.Contains(err.Error(), vt.expected) {
+			t.Errorf("err %d:validateWorkflow() = %v, want:%s", i, err, vt.expected)
+		}
+	}
+}
+
+func TestRunSteps(t *testing.T) {
+	wf, err := loadWorkflow(testWorkflowFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var order []string
+	fail := map[string]bool{}
+	runStep := func(w io.Writer, step workflowStep) (int, error) {
+		mu.Lock()
+		order = append(order, step.Name)
+		mu.Unlock()
+		fmt.Fprintf(w, "%s output\n", step.Name)
+		if fail[step.Name] {
+			return 3, nil
+		}
+		return 0, nil
+	}
+	var b bytes.Buffer
+	results := runSteps(&b, wf, runStep)
+	if code, err := printWorkflowResults(&b, wf, results); code != 0 || err != nil {
+		t.Errorf("printWorkflowResults() = %d, %v", code, err)
+	}
+	index := map[string]int{}
+	for i, name := range order {
+		index[name] = i
+	}
+	if len(order) != 4 || index["migrate"] > index["seed"] || index["seed"] > index["smoke"] || index["assets"] > index["smoke"] {
+		t.Errorf("runSteps() order = %v", order)
+	}
+	if !strings.Contains(b.String(), "=== step seed: succeeded, exit code 0 (0s) ===\nseed output\n") {
+		t.Errorf("runSteps() output = %s", b.String())
+	}
+
+	order = nil
+	fail["seed"] = true
+	b.Reset()
+	results = runSteps(&b, wf, runStep)
+	if results["seed"].status != stepFailed || results["smoke"].status != stepSkipped || results["assets"].status != stepSucceeded {
+		t.Errorf("runSteps() = %v", results)
+	}
+	if len(order) != 3 {
+		t.Errorf("runSteps() ran %v, want smoke skipped", order)
+	}
+	code, err := printWorkflowResults(&b, wf, results)
+	if code != 3 || err == nil || err.Error() != "2 of 4 steps did not succeed" {
+		t.Errorf("printWorkflowResults() = %d, %v", code, err)
+	}
+}
+
+func TestCreateStepEnv(t *testing.T) {
+	e := environments{TaskDefinition: "app:1", Envs: []string{"A=1"}}
+	res := createStepEnv(e, workflowStep{TaskDefinition: "app:2", Env: map[string]string{"C": "3", "B": "2"}})
+	if res.TaskDefinition != "app:2" || strings.Join(res.Envs, ",") != "A=1,B=2,C=3" {
+		t.Errorf("createStepEnv() = %s, %v", res.TaskDefinition, res.Envs)
+	}
+	if len(e.Envs) != 1 {
+		t.Errorf("createStepEnv() modified ENVS: %v", e.Envs)
+	}
+}